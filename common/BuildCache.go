@@ -0,0 +1,232 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// CacheDirEnv overrides the location of the build cache.
+const CacheDirEnv = "EXEPY_CACHE_DIR"
+
+// buildCacheFormat is part of every cache key; bump it when the layout of cached entries changes.
+const buildCacheFormat = "1"
+
+// Kinds of build cache entries.
+const (
+	CacheKindRuntime    = "runtime"
+	CacheKindWheelhouse = "wheels"
+)
+
+// BuildCache stores prepared Python runtimes and wheelhouses between builds, addressed by a hash of their inputs.
+// A nil *BuildCache is a disabled cache: nothing is restored and nothing is stored.
+type BuildCache struct {
+	dir string
+}
+
+// OpenBuildCache opens the build cache in the user cache directory, or in $EXEPY_CACHE_DIR if set.
+func OpenBuildCache() (*BuildCache, error) {
+	dir := os.Getenv(CacheDirEnv)
+	if dir == "" {
+		userCacheDir, err := os.UserCacheDir()
+		if err != nil {
+			return nil, fmt.Errorf("error locating the user cache directory: %w", err)
+		}
+		dir = filepath.Join(userCacheDir, "exepy")
+	}
+
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &BuildCache{dir: dir}, nil
+}
+
+// Dir returns the directory holding the cache.
+func (c *BuildCache) Dir() string {
+	return c.dir
+}
+
+// CacheKey derives a cache key from the inputs that determine an entry's contents.
+func CacheKey(inputs ...string) string {
+	hash := sha256.New()
+	hash.Write([]byte(buildCacheFormat))
+	for _, input := range inputs {
+		// length-prefix each input so different splits of the same text give different keys
+		fmt.Fprintf(hash, "\x00%d:%s", len(input), input)
+	}
+	return hex.EncodeToString(hash.Sum(nil))
+}
+
+// HashFileContents returns the SHA-256 of a file, or "" if path is empty or the file does not exist.
+// It is meant for cache keys that depend on an optional input file.
+func HashFileContents(path string) (string, error) {
+	if path == "" || !DoesPathExist(path) {
+		return "", nil
+	}
+	return Sha256SumFile(path)
+}
+
+func (c *BuildCache) entryDir(kind, key string) string {
+	return filepath.Join(c.dir, kind, key)
+}
+
+// Restore copies the cached entry into dest and reports whether there was one.
+func (c *BuildCache) Restore(kind, key, dest string) (bool, error) {
+	if c == nil {
+		return false, nil
+	}
+
+	entry := c.entryDir(kind, key)
+	if !DoesPathExist(entry) {
+		return false, nil
+	}
+
+	if err := copyDir(entry, dest); err != nil {
+		return false, fmt.Errorf("error restoring cached %s: %w", kind, err)
+	}
+
+	// the modification time records the last use, for Prune
+	now := time.Now()
+	_ = os.Chtimes(entry, now, now)
+
+	return true, nil
+}
+
+// Store copies src into the cache. The entry only becomes visible once it is complete,
+// so an interrupted build never leaves a partial entry behind.
+func (c *BuildCache) Store(kind, key, src string) error {
+	if c == nil {
+		return nil
+	}
+
+	entry := c.entryDir(kind, key)
+	if err := os.MkdirAll(filepath.Dir(entry), os.ModePerm); err != nil {
+		return err
+	}
+
+	staging, err := os.MkdirTemp(filepath.Dir(entry), key+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(staging)
+
+	if err := copyDir(src, staging); err != nil {
+		return fmt.Errorf("error caching %s: %w", kind, err)
+	}
+
+	// another build may have stored the same entry in the meantime
+	if DoesPathExist(entry) {
+		return nil
+	}
+
+	return os.Rename(staging, entry)
+}
+
+// Prune removes the entries that have not been used for maxAge, or every entry if maxAge is zero.
+// It returns the number of entries removed and the bytes freed.
+func (c *BuildCache) Prune(maxAge time.Duration) (int, int64, error) {
+	if c == nil {
+		return 0, 0, nil
+	}
+
+	removed := 0
+	var freed int64
+
+	for _, kind := range []string{CacheKindRuntime, CacheKindWheelhouse} {
+		entries, err := os.ReadDir(filepath.Join(c.dir, kind))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return removed, freed, err
+		}
+
+		for _, entry := range entries {
+			info, err := entry.Info()
+			if err != nil {
+				return removed, freed, err
+			}
+
+			if maxAge > 0 && time.Since(info.ModTime()) < maxAge {
+				continue
+			}
+
+			entryPath := filepath.Join(c.dir, kind, entry.Name())
+			size, _ := dirSize(entryPath)
+
+			if err := os.RemoveAll(entryPath); err != nil {
+				return removed, freed, err
+			}
+
+			removed++
+			freed += size
+		}
+	}
+
+	return removed, freed, nil
+}
+
+// copyDir copies the files below src into dst, creating directories as needed.
+func copyDir(src, dst string) error {
+	return filepath.WalkDir(src, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(src, path)
+		if err != nil {
+			return err
+		}
+		target := filepath.Join(dst, relativePath)
+
+		if d.IsDir() {
+			return os.MkdirAll(target, os.ModePerm)
+		}
+
+		return copyFileContents(path, target)
+	})
+}
+
+func copyFileContents(src, dst string) error {
+	from, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer from.Close()
+
+	to, err := os.Create(dst)
+	if err != nil {
+		return err
+	}
+	defer to.Close()
+
+	if _, err := io.Copy(to, from); err != nil {
+		return err
+	}
+
+	return to.Close()
+}
+
+func dirSize(dir string) (int64, error) {
+	var size int64
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			info, err := d.Info()
+			if err != nil {
+				return err
+			}
+			size += info.Size()
+		}
+		return nil
+	})
+	return size, err
+}
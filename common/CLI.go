@@ -8,8 +8,15 @@ import (
 	"time"
 )
 
+// AssumeYes makes prompts continue without waiting for input, answering yes to every question.
+// It is set for unattended installations.
+var AssumeYes bool
+
 func PressButtonToContinue(continueMessage string) {
 	fmt.Println(continueMessage)
+	if AssumeYes {
+		return
+	}
 	fmt.Println(".")
 	fmt.Print("\a")
 
@@ -42,3 +49,29 @@ func PressButtonToContinue(continueMessage string) {
 
 	stop <- true
 }
+
+// AskYesNo prompts the user with a yes/no question and returns their answer.
+// An empty answer returns defaultYes; with AssumeYes the answer is always yes.
+func AskYesNo(question string, defaultYes bool) bool {
+	options := "[y/N]"
+	if defaultYes {
+		options = "[Y/n]"
+	}
+
+	fmt.Print(question, " ", options, " ")
+
+	if AssumeYes {
+		fmt.Println("yes")
+		return true
+	}
+
+	reader := bufio.NewReader(os.Stdin)
+	answer, _ := reader.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+
+	if answer == "" {
+		return defaultYes
+	}
+
+	return answer == "y" || answer == "yes"
+}
@@ -1,35 +1,170 @@
 package common
 
 import (
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
-	"path/filepath"
+	"sort"
+	"strings"
+	"time"
 )
 
-func RunCommand(command string, args []string) error {
-	cmd, err := createCommand(command, args)
-	if err != nil {
-		return err
-	}
+// InstallOutputLogFilename is the file in the install root that receives the output of the commands run during installation.
+const InstallOutputLogFilename = "install-output.log"
+
+// pythonEnvironment lists variables that make a Python interpreter load another installation's modules or settings.
+var pythonEnvironment = []string{"PYTHONHOME", "PYTHONPATH"}
 
-	println("Running command:", cmd.String())
-	return cmd.Run()
+// CommandRunner runs commands with a common working directory, environment and output log.
+// The zero value runs commands in the current working directory with the inherited environment,
+// except for the variables in pythonEnvironment.
+type CommandRunner struct {
+	// Context cancels running commands; nil uses context.Background.
+	Context context.Context
+	// Dir is the working directory; empty uses the current working directory.
+	Dir string
+	// Env holds variables set on top of the inherited environment.
+	Env map[string]string
+	// InheritPythonEnv keeps PYTHONHOME and PYTHONPATH of the calling environment. They are removed by default,
+	// since they make the embedded Python import modules, or pip read configuration, of another installation.
+	InheritPythonEnv bool
+	// Timeout limits the duration of each command; zero means no limit.
+	Timeout time.Duration
+	// Log receives the command line and a copy of the output of each command, if set.
+	Log io.Writer
 }
 
-func createCommand(command string, args []string) (*exec.Cmd, error) {
-	cmd := exec.Command(command, args...)
+// CommandError reports a command that could not be started, failed, or was stopped.
+type CommandError struct {
+	Command string
+	// ExitCode is the exit code of the command, or -1 if it did not exit by itself.
+	ExitCode int
+	Err      error
+}
 
-	execPath, err := os.Executable()
-	if err != nil {
-		return nil, fmt.Errorf("error getting executable path: %v", err)
+func (e *CommandError) Error() string {
+	if e.ExitCode >= 0 {
+		return fmt.Sprintf("%s exited with code %d", e.Command, e.ExitCode)
 	}
+	return fmt.Sprintf("%s: %v", e.Command, e.Err)
+}
+
+func (e *CommandError) Unwrap() error {
+	return e.Err
+}
+
+// RunCommand runs the command with dir as its working directory.
+// An empty dir runs the command in the current working directory.
+func RunCommand(dir string, command string, args []string) error {
+	runner := CommandRunner{Dir: dir}
+	return runner.Run(command, args...)
+}
+
+// Run runs the command with the console's input and output, copying the output to the log.
+func (r *CommandRunner) Run(command string, args ...string) error {
+	return r.run(command, args, os.Stdout)
+}
 
-	exeDir := filepath.Dir(execPath)
-	cmd.Dir = exeDir
+// Output runs the command and returns its standard output instead of printing it.
+// Standard error is still printed, and both are copied to the log.
+func (r *CommandRunner) Output(command string, args ...string) ([]byte, error) {
+	var output bytes.Buffer
+	err := r.run(command, args, &output)
+	return output.Bytes(), err
+}
 
+func (r *CommandRunner) run(command string, args []string, stdout io.Writer) error {
+	ctx := r.Context
+	if ctx == nil {
+		ctx = context.Background()
+	}
+	if r.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.Timeout)
+		defer cancel()
+	}
+
+	cmd := exec.CommandContext(ctx, command, args...)
+	cmd.Dir = r.Dir
+	cmd.Env = r.environment(os.Environ())
 	cmd.Stdin = os.Stdin
-	cmd.Stdout = os.Stdout
+	cmd.Stdout = stdout
 	cmd.Stderr = os.Stderr
-	return cmd, nil
+
+	fmt.Println("Running command:", cmd.String())
+
+	if r.Log != nil {
+		fmt.Fprintln(r.Log, "> "+cmd.String())
+		cmd.Stdout = io.MultiWriter(stdout, r.Log)
+		cmd.Stderr = io.MultiWriter(os.Stderr, r.Log)
+	}
+
+	err := cmd.Run()
+	if err == nil {
+		return nil
+	}
+
+	commandError := &CommandError{Command: cmd.String(), ExitCode: -1, Err: err}
+
+	var exitErr *exec.ExitError
+	if ctx.Err() != nil {
+		// the process was killed, so its exit code says nothing
+		commandError.Err = ctx.Err()
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) && r.Timeout > 0 {
+			commandError.Err = fmt.Errorf("timed out after %s", r.Timeout)
+		}
+	} else if errors.As(err, &exitErr) {
+		commandError.ExitCode = exitErr.ExitCode()
+	}
+
+	if r.Log != nil {
+		fmt.Fprintln(r.Log, "Error:", commandError)
+	}
+
+	return commandError
+}
+
+func (r *CommandRunner) environment(environ []string) []string {
+	var removed []string
+	if !r.InheritPythonEnv {
+		removed = pythonEnvironment
+	}
+	return MergeEnvironment(environ, removed, r.Env)
+}
+
+// MergeEnvironment returns environ without the variables in removed, with the variables in set added or replacing
+// existing ones. Names are compared case-insensitively, as Windows does.
+func MergeEnvironment(environ []string, removed []string, set map[string]string) []string {
+	replaced := make(map[string]bool, len(removed)+len(set))
+	for _, name := range removed {
+		replaced[strings.ToUpper(name)] = true
+	}
+	for name := range set {
+		replaced[strings.ToUpper(name)] = true
+	}
+
+	env := make([]string, 0, len(environ)+len(set))
+	for _, variable := range environ {
+		name, _, _ := strings.Cut(variable, "=")
+		if replaced[strings.ToUpper(name)] {
+			continue
+		}
+		env = append(env, variable)
+	}
+
+	names := make([]string, 0, len(set))
+	for name := range set {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		env = append(env, name+"="+set[name])
+	}
+
+	return env
 }
@@ -1,34 +1,69 @@
 package common
 
 import (
-	"encoding/json"
 	"errors"
 	"fmt"
 	"os"
+	"path/filepath"
 )
 
 // PythonSetupSettings holds the configuration settings.
 type PythonSetupSettings struct {
-	ApplicationName       *string  `json:"applicationName"`
-	RunScriptFileStem     *string  `json:"runScriptFileStem"`
-	PythonDownloadURL     *string  `json:"pythonDownloadURL"`
-	PipDownloadURL        *string  `json:"pipDownloadURL"`
-	PythonDownloadZip     *string  `json:"pythonDownloadFile"`
-	PythonExtractDir      *string  `json:"pythonExtractDir"`
-	ScriptExtractDir      *string  `json:"scriptExtractDir"`
-	PthFile               *string  `json:"pthFile"`
-	PythonInteriorZip     *string  `json:"pythonInteriorZip"`
-	InstallerRequirements *string  `json:"installerRequirements"`
-	RequirementsFile      *string  `json:"requirementsFile"`
-	ScriptDir             *string  `json:"scriptDir"`
-	SetupScript           *string  `json:"setupScript"`
-	MainScript            *string  `json:"mainScript"`
-	FilesToCopyToRoot     []string `json:"filesToCopyToRoot"`
-	RunAfterInstall       *bool    `json:"runAfterInstall"`
-	OnlineRequirements    *bool    `json:"onlineRequirements"`
-	IgnoredPathParts      []string `json:"ignoredPathParts"`
+	Schema                *string               `json:"$schema,omitempty"`
+	ConfigVersion         *int                  `json:"configVersion"`
+	ApplicationName       *string               `json:"applicationName"`
+	Version               *string               `json:"version"`
+	Publisher             *string               `json:"publisher"`
+	Description           *string               `json:"description"`
+	AppID                 *string               `json:"appId"`
+	VersionPolicy         *string               `json:"versionPolicy"`
+	OutputName            *string               `json:"outputName"`
+	RunScriptFileStem     *string               `json:"runScriptFileStem"`
+	Launcher              *string               `json:"launcher"`
+	PythonVersion         *string               `json:"pythonVersion"`
+	Arch                  *string               `json:"arch"`
+	PythonDownloadURL     *string               `json:"pythonDownloadURL"`
+	PipDownloadURL        *string               `json:"pipDownloadURL"`
+	PythonSha256          *string               `json:"pythonSha256"`
+	PipSha256             *string               `json:"pipSha256"`
+	DownloadProxy         *string               `json:"downloadProxy"`
+	DownloadCAFile        *string               `json:"downloadCAFile"`
+	PythonDownloadZip     *string               `json:"pythonDownloadFile"`
+	PythonExtractDir      *string               `json:"pythonExtractDir"`
+	ScriptExtractDir      *string               `json:"scriptExtractDir"`
+	InstallDir            *string               `json:"installDir"`
+	PthFile               *string               `json:"pthFile"`
+	PythonInteriorZip     *string               `json:"pythonInteriorZip"`
+	PythonPaths           []string              `json:"pythonPaths"`
+	PythonIsolated        *bool                 `json:"pythonIsolated"`
+	SiteCustomize         *string               `json:"siteCustomize"`
+	InstallerRequirements *string               `json:"installerRequirements"`
+	RequirementsFile      *string               `json:"requirementsFile"`
+	ScriptDir             *string               `json:"scriptDir"`
+	SetupScript           *string               `json:"setupScript"`
+	MainScript            *string               `json:"mainScript"`
+	MainModule            *string               `json:"mainModule"`
+	PythonFlags           []string              `json:"pythonFlags"`
+	Env                   map[string]string     `json:"env"`
+	WorkingDirectory      *string               `json:"workingDirectory"`
+	EntryPoints           map[string]EntryPoint `json:"entryPoints"`
+	DefaultEntryPoint     *string               `json:"defaultEntryPoint"`
+	FilesToCopyToRoot     []string              `json:"filesToCopyToRoot"`
+	Payload               []PayloadMapping      `json:"payload"`
+	RunAfterInstall       *bool                 `json:"runAfterInstall"`
+	OnlineRequirements    *bool                 `json:"onlineRequirements"`
+	PipIsolated           *bool                 `json:"pipIsolated"`
+	PipOptions            []string              `json:"pipOptions"`
+	IgnoredPathParts      []string              `json:"ignoredPathParts"`
+	SmokeTestArgs         []string              `json:"smokeTestArgs"`
+	SmokeTestScript       *string               `json:"smokeTestScript"`
+	SmokeTestOutput       *string               `json:"smokeTestOutput"`
+	IntegrityIgnore       []string              `json:"integrityIgnore"`
 }
 
+// DefaultIntegrityIgnore lists runtime artifacts that are not reported as unexpected files by integrity checks.
+var DefaultIntegrityIgnore = []string{"__pycache__", "*.pyc", "*.log"}
+
 // Validate checks if the required fields are present.
 func (s *PythonSetupSettings) Validate() (err error) {
 	// Recover from any unexpected panics
@@ -42,6 +77,24 @@ func (s *PythonSetupSettings) Validate() (err error) {
 		return errors.New("missing required field: ApplicationName")
 	}
 
+	if s.Version == nil {
+		return errors.New("missing required field: version")
+	}
+
+	if s.VersionPolicy == nil {
+		return errors.New("missing required field: versionPolicy")
+	}
+	if err := validateVersionPolicy(*s.VersionPolicy); err != nil {
+		return err
+	}
+
+	if s.OutputName == nil {
+		return errors.New("missing required field: outputName")
+	}
+	if *s.OutputName == "" {
+		return errors.New("required field is empty: outputName")
+	}
+
 	if s.RunScriptFileStem == nil {
 		return errors.New("missing required field: RunScriptFileStem")
 	}
@@ -50,7 +103,24 @@ func (s *PythonSetupSettings) Validate() (err error) {
 		return errors.New("required field is empty: RunScriptFileStem")
 	}
 
+	if s.Launcher == nil {
+		return errors.New("missing required field: launcher")
+	}
+	if err := validateLauncher(*s.Launcher); err != nil {
+		return err
+	}
+
 	// Check each pointer field before de-referencing it.
+	if s.PythonVersion == nil {
+		return errors.New("missing required field: pythonVersion")
+	}
+	if s.Arch == nil {
+		return errors.New("missing required field: arch")
+	}
+	if err := validatePythonVersion(*s.PythonVersion, *s.Arch); err != nil {
+		return err
+	}
+
 	if s.PythonDownloadURL == nil {
 		return errors.New("missing required field: pythonDownloadURL")
 	}
@@ -89,28 +159,51 @@ func (s *PythonSetupSettings) Validate() (err error) {
 	if s.MainScript == nil {
 		return errors.New("missing required field: mainScript")
 	}
+	if s.MainModule == nil {
+		return errors.New("missing required field: mainModule")
+	}
+	if s.WorkingDirectory == nil {
+		return errors.New("missing required field: workingDirectory")
+	}
+	if err := validateWorkingDirectory(*s.WorkingDirectory); err != nil {
+		return err
+	}
+	if err := validatePythonFlags(s.PythonFlags); err != nil {
+		return err
+	}
+	if s.PipIsolated == nil {
+		return errors.New("missing required field: pipIsolated")
+	}
+	if err := validatePipOptions(s.PipOptions); err != nil {
+		return err
+	}
+	if s.SmokeTestScript == nil {
+		return errors.New("missing required field: smokeTestScript")
+	}
+	if s.SmokeTestOutput == nil {
+		return errors.New("missing required field: smokeTestOutput")
+	}
 
 	// If all validations pass, return nil
 	return nil
 }
 
-// loadSettings reads the configuration file and unmarshals it.
+// loadSettings reads the configuration file and unmarshals it, migrating older versions.
 func loadSettings(filename string) (*PythonSetupSettings, error) {
 	data, err := os.ReadFile(filename)
 	if err != nil {
 		return nil, err
 	}
-	var settings PythonSetupSettings
-	err = json.Unmarshal(data, &settings)
+	settings, _, err := decodeSettings(filename, data)
 	if err != nil {
 		return nil, err
 	}
-	return &settings, nil
+	return settings, nil
 }
 
-// saveSettings writes the configuration to the file.
+// saveSettings writes the configuration to the file, in the format given by its extension.
 func saveSettings(filename string, settings *PythonSetupSettings) error {
-	data, err := json.MarshalIndent(settings, "", "  ")
+	data, err := encodeSettings(filename, settings)
 	if err != nil {
 		return err
 	}
@@ -129,14 +222,45 @@ func boolPtr(b bool) *bool {
 	return &b
 }
 
+func intPtr(i int) *int {
+	return &i
+}
+
 // mergeSettings fills in any nil fields in loaded with defaults.
 func mergeSettings(loaded, defaults *PythonSetupSettings) *PythonSetupSettings {
+	if loaded.ConfigVersion == nil {
+		loaded.ConfigVersion = defaults.ConfigVersion
+	}
+	if loaded.RunScriptFileStem == nil {
+		loaded.RunScriptFileStem = defaults.RunScriptFileStem
+	}
+	if loaded.Launcher == nil {
+		loaded.Launcher = defaults.Launcher
+	}
+	if loaded.PythonVersion == nil {
+		loaded.PythonVersion = defaults.PythonVersion
+	}
+	if loaded.Arch == nil {
+		loaded.Arch = defaults.Arch
+	}
 	if loaded.PythonDownloadURL == nil {
 		loaded.PythonDownloadURL = defaults.PythonDownloadURL
 	}
 	if loaded.PipDownloadURL == nil {
 		loaded.PipDownloadURL = defaults.PipDownloadURL
 	}
+	if loaded.PythonSha256 == nil {
+		loaded.PythonSha256 = defaults.PythonSha256
+	}
+	if loaded.PipSha256 == nil {
+		loaded.PipSha256 = defaults.PipSha256
+	}
+	if loaded.DownloadProxy == nil {
+		loaded.DownloadProxy = defaults.DownloadProxy
+	}
+	if loaded.DownloadCAFile == nil {
+		loaded.DownloadCAFile = defaults.DownloadCAFile
+	}
 	if loaded.PythonDownloadZip == nil {
 		loaded.PythonDownloadZip = defaults.PythonDownloadZip
 	}
@@ -146,12 +270,21 @@ func mergeSettings(loaded, defaults *PythonSetupSettings) *PythonSetupSettings {
 	if loaded.ScriptExtractDir == nil {
 		loaded.ScriptExtractDir = defaults.ScriptExtractDir
 	}
+	if loaded.InstallDir == nil {
+		loaded.InstallDir = defaults.InstallDir
+	}
 	if loaded.PthFile == nil {
 		loaded.PthFile = defaults.PthFile
 	}
 	if loaded.PythonInteriorZip == nil {
 		loaded.PythonInteriorZip = defaults.PythonInteriorZip
 	}
+	if loaded.PythonIsolated == nil {
+		loaded.PythonIsolated = defaults.PythonIsolated
+	}
+	if loaded.SiteCustomize == nil {
+		loaded.SiteCustomize = defaults.SiteCustomize
+	}
 	if loaded.InstallerRequirements == nil {
 		loaded.InstallerRequirements = defaults.InstallerRequirements
 	}
@@ -167,6 +300,15 @@ func mergeSettings(loaded, defaults *PythonSetupSettings) *PythonSetupSettings {
 	if loaded.MainScript == nil {
 		loaded.MainScript = defaults.MainScript
 	}
+	if loaded.MainModule == nil {
+		loaded.MainModule = defaults.MainModule
+	}
+	if loaded.WorkingDirectory == nil {
+		loaded.WorkingDirectory = defaults.WorkingDirectory
+	}
+	if loaded.DefaultEntryPoint == nil {
+		loaded.DefaultEntryPoint = defaults.DefaultEntryPoint
+	}
 
 	if loaded.RunAfterInstall == nil {
 		loaded.RunAfterInstall = defaults.RunAfterInstall
@@ -176,50 +318,118 @@ func mergeSettings(loaded, defaults *PythonSetupSettings) *PythonSetupSettings {
 		loaded.OnlineRequirements = defaults.OnlineRequirements
 	}
 
+	if loaded.PipIsolated == nil {
+		loaded.PipIsolated = defaults.PipIsolated
+	}
+
+	if loaded.SmokeTestScript == nil {
+		loaded.SmokeTestScript = defaults.SmokeTestScript
+	}
+
+	if loaded.SmokeTestOutput == nil {
+		loaded.SmokeTestOutput = defaults.SmokeTestOutput
+	}
+
 	if loaded.ApplicationName == nil {
 		loaded.ApplicationName = defaults.ApplicationName
 	}
 
-	// we can safely ignore FilesToCopyToRoot and IgnoredPathParts
+	if loaded.Version == nil {
+		loaded.Version = defaults.Version
+	}
+
+	if loaded.OutputName == nil {
+		loaded.OutputName = defaults.OutputName
+	}
+
+	if loaded.Publisher == nil {
+		loaded.Publisher = defaults.Publisher
+	}
+
+	if loaded.Description == nil {
+		loaded.Description = defaults.Description
+	}
+
+	if loaded.AppID == nil {
+		loaded.AppID = defaults.AppID
+	}
+
+	if loaded.VersionPolicy == nil {
+		loaded.VersionPolicy = defaults.VersionPolicy
+	}
+
+	if loaded.IntegrityIgnore == nil {
+		loaded.IntegrityIgnore = defaults.IntegrityIgnore
+	}
+
+	// we can safely ignore FilesToCopyToRoot, IgnoredPathParts, PythonPaths, PythonFlags, Env, EntryPoints, PipOptions and SmokeTestArgs
 
 	// RunAfterInstall is a bool; false is a valid default.
 	return loaded
 }
 
-// LoadOrSaveDefault loads settings from the file or creates a default configuration if necessary.
-func LoadOrSaveDefault(filename string) (*PythonSetupSettings, error) {
-	// Define default settings.
-	defaults := &PythonSetupSettings{
+// DefaultSettings returns the settings used for any field a configuration file leaves out.
+func DefaultSettings() *PythonSetupSettings {
+	return &PythonSetupSettings{
+		ConfigVersion:         intPtr(CurrentConfigVersion),
 		ApplicationName:       strPtr("Python Program"),
+		Version:               strPtr("1.0.0"),
+		Publisher:             strPtr(""),
+		Description:           strPtr(""),
+		AppID:                 strPtr(""),
+		VersionPolicy:         strPtr(VersionPolicyRefuseDowngrade),
+		OutputName:            strPtr("installer.exe"),
 		RunScriptFileStem:     strPtr("run"),
-		PythonDownloadURL:     strPtr("https://www.python.org/ftp/python/3.11.7/python-3.11.7-embed-amd64.zip"),
+		Launcher:              strPtr(LauncherConsole),
+		PythonVersion:         strPtr("3.11.7"),
+		Arch:                  strPtr("amd64"),
 		PipDownloadURL:        strPtr("https://bootstrap.pypa.io/pip/pip.pyz"),
-		PythonDownloadZip:     strPtr("python code-3.11.7-embed-amd64.zip"),
+		PythonSha256:          strPtr(""),
+		PipSha256:             strPtr(""),
+		DownloadProxy:         strPtr(""),
+		DownloadCAFile:        strPtr(""),
 		PythonExtractDir:      strPtr("python-embed"),
 		ScriptExtractDir:      strPtr("scripts"),
-		PthFile:               strPtr("python311._pth"),
-		PythonInteriorZip:     strPtr("python311.zip"),
+		InstallDir:            strPtr(""),
+		PythonIsolated:        boolPtr(false),
+		SiteCustomize:         strPtr(""),
 		InstallerRequirements: strPtr(""),
 		RequirementsFile:      strPtr("requirements.txt"),
 		ScriptDir:             strPtr("scripts"),
 		SetupScript:           strPtr(""),
 		MainScript:            strPtr("main.py"),
+		MainModule:            strPtr(""),
+		WorkingDirectory:      strPtr(WorkingDirectoryInherit),
+		DefaultEntryPoint:     strPtr(""),
 		FilesToCopyToRoot:     []string{"requirements.txt", "readme.md", "license.md"},
 		RunAfterInstall:       boolPtr(false),
 		OnlineRequirements:    boolPtr(false),
+		PipIsolated:           boolPtr(true),
 		IgnoredPathParts:      []string{"__pycache__", ".git", ".idea", ".vscode"},
+		SmokeTestScript:       strPtr(""),
+		SmokeTestOutput:       strPtr(""),
+		IntegrityIgnore:       DefaultIntegrityIgnore,
 	}
+}
 
-	// Attempt to load the existing configuration.
+// LoadSettings loads settings from the file, applies the overrides and fills in defaults for the fields left out.
+// The file may be JSON, YAML or TOML. It is never modified; use InitSettings to create one.
+func LoadSettings(filename string, overrides []SettingOverride) (*PythonSetupSettings, error) {
 	loaded, err := loadSettings(filename)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("%s not found; run \"exepy init\" to create a starter configuration", filename)
+	}
 	if err != nil {
-		// If the file doesn't exist or can't be read, save defaults.
-		if saveErr := saveSettings(filename, defaults); saveErr != nil {
-			return nil, saveErr
-		}
-		return defaults, nil
+		return nil, err
+	}
+
+	loaded, err = applyOverrides(loaded, overrides)
+	if err != nil {
+		return nil, err
 	}
 
+	defaults := DefaultSettings()
+	resolvePythonDistribution(loaded, defaults)
 	merged := mergeSettings(loaded, defaults)
 
 	// Validate the merged configuration.
@@ -227,10 +437,19 @@ func LoadOrSaveDefault(filename string) (*PythonSetupSettings, error) {
 		return nil, err
 	}
 
-	// Save the merged configuration back to the file.
-	if err := saveSettings(filename, merged); err != nil {
-		return nil, err
+	if err := merged.ValidateSemantics(filepath.Dir(filename)); err != nil {
+		return nil, fmt.Errorf("invalid configuration in %s:\n%w", filename, err)
 	}
 
 	return merged, nil
 }
+
+// InitSettings writes a starter configuration containing the default settings, in the format given by the file's extension.
+// An existing file is only replaced when overwrite is set.
+func InitSettings(filename string, overwrite bool) error {
+	if !overwrite && DoesPathExist(filename) {
+		return fmt.Errorf("%s already exists", filename)
+	}
+
+	return saveSettings(filename, DefaultSettings())
+}
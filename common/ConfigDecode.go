@@ -0,0 +1,330 @@
+package common
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+// CurrentConfigVersion is the configVersion written by this release.
+const CurrentConfigVersion = 1
+
+// configMigrations upgrade a configuration one version at a time: configMigrations[i] migrates version i to i+1
+// and reports whether it rewrote any keys. Configurations without a configVersion are version 0.
+var configMigrations = []func(config map[string]json.RawMessage) (bool, error){
+	// 0 -> 1: pythonVersion and arch were introduced.
+	migratePythonVersion,
+}
+
+// migratePythonVersion sets pythonVersion and arch from the file name in pythonDownloadURL, which was the only place
+// configurations written before those settings existed named the Python distribution.
+func migratePythonVersion(config map[string]json.RawMessage) (bool, error) {
+	raw, ok := config["pythonDownloadURL"]
+	if !ok {
+		return false, nil
+	}
+
+	var downloadURL string
+	if err := json.Unmarshal(raw, &downloadURL); err != nil {
+		return false, fmt.Errorf("pythonDownloadURL: %w", err)
+	}
+
+	match := pythonEmbedPattern.FindStringSubmatch(path.Base(downloadURL))
+	if match == nil {
+		return false, nil
+	}
+
+	rewritten := false
+	if _, ok := config["pythonVersion"]; !ok {
+		config["pythonVersion"] = jsonString(match[1])
+		rewritten = true
+	}
+	if _, ok := config["arch"]; !ok && match[4] != "" {
+		config["arch"] = jsonString(match[4])
+		rewritten = true
+	}
+
+	return rewritten, nil
+}
+
+// jsonString returns s encoded as a JSON string.
+func jsonString(s string) json.RawMessage {
+	data, _ := json.Marshal(s)
+	return data
+}
+
+// decodeSettings parses a configuration file strictly.
+// YAML and TOML files are converted to JSON after their keys are checked. Unknown keys, syntax errors
+// and type errors are reported with their position where the format allows it, and configurations
+// written for older versions are migrated. It reports whether a migration rewrote any keys.
+func decodeSettings(filename string, data []byte) (*PythonSetupSettings, bool, error) {
+	settingsType := reflect.TypeOf(PythonSetupSettings{})
+
+	// positions in converted documents would not match the user's file
+	decodeError := func(err error) error {
+		return fmt.Errorf("%s: %w", filename, err)
+	}
+
+	var err error
+	switch configFormat(filename) {
+	case formatYAML:
+		data, err = yamlToJSON(filename, data, settingsType)
+	case formatTOML:
+		data, err = tomlToJSON(filename, data, settingsType)
+	default:
+		err = checkUnknownKeys(filename, data, settingsType)
+		decodeError = func(err error) error {
+			return positionedError(filename, data, err)
+		}
+	}
+	if err != nil {
+		return nil, false, err
+	}
+
+	// decode the file as written first, so type errors point at the user's input
+	var settings PythonSetupSettings
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return nil, false, decodeError(err)
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, false, decodeError(err)
+	}
+	if config == nil {
+		return nil, false, fmt.Errorf("%s: expected an object of settings", filename)
+	}
+
+	version := 0
+	if raw, ok := config["configVersion"]; ok {
+		if err := json.Unmarshal(raw, &version); err != nil {
+			return nil, false, fmt.Errorf("%s: configVersion must be an integer", filename)
+		}
+	}
+
+	if version > CurrentConfigVersion {
+		return nil, false, fmt.Errorf("%s: configVersion %d is newer than the supported version %d", filename, version, CurrentConfigVersion)
+	}
+
+	fileVersion := version
+	migrated := false
+	for ; version < CurrentConfigVersion; version++ {
+		rewritten, err := configMigrations[version](config)
+		if err != nil {
+			return nil, false, fmt.Errorf("%s: error migrating from configVersion %d: %w", filename, version, err)
+		}
+		migrated = migrated || rewritten
+	}
+
+	if migrated {
+		fmt.Printf("Note: %s uses configVersion %d and was migrated to configVersion %d in memory. Run \"exepy config show\" to see the migrated settings and update the file to silence this note.\n", filename, fileVersion, CurrentConfigVersion)
+		config["configVersion"] = json.RawMessage(fmt.Sprint(CurrentConfigVersion))
+
+		migratedData, err := json.Marshal(config)
+		if err != nil {
+			return nil, false, err
+		}
+
+		settings = PythonSetupSettings{}
+		if err := json.Unmarshal(migratedData, &settings); err != nil {
+			return nil, false, fmt.Errorf("%s: error decoding migrated configuration: %w", filename, err)
+		}
+	}
+
+	return &settings, migrated, nil
+}
+
+// checkUnknownKeys walks the JSON document alongside the Go type and reports every object key
+// that does not correspond to a field.
+func checkUnknownKeys(filename string, data []byte, t reflect.Type) error {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+
+	var unknown []error
+	if err := walkJSONValue(decoder, data, t, func(key string, offset int64, known []string) {
+		line, column := lineAndColumn(data, offset)
+		unknown = append(unknown, fmt.Errorf("%s:%d:%d: %s", filename, line, column, unknownKeyMessage(key, known)))
+	}); err != nil {
+		return positionedError(filename, data, err)
+	}
+
+	return errors.Join(unknown...)
+}
+
+// unknownKeyMessage describes an unknown key, suggesting the closest known key.
+func unknownKeyMessage(key string, known []string) string {
+	message := fmt.Sprintf("unknown key %q", key)
+	if suggestion := closestKey(key, known); suggestion != "" {
+		message += fmt.Sprintf(" (did you mean %q?)", suggestion)
+	}
+	return message
+}
+
+// walkJSONValue consumes one JSON value from the decoder. Keys of objects decoded into structs are
+// checked against the struct's JSON field names. A nil type accepts any value.
+func walkJSONValue(decoder *json.Decoder, data []byte, t reflect.Type, report func(key string, offset int64, known []string)) error {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	token, err := decoder.Token()
+	if err != nil {
+		return err
+	}
+
+	delim, ok := token.(json.Delim)
+	if !ok {
+		return nil
+	}
+
+	switch delim {
+	case '{':
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+
+		for decoder.More() {
+			keyToken, err := decoder.Token()
+			if err != nil {
+				return err
+			}
+			key, _ := keyToken.(string)
+
+			var valueType reflect.Type
+			switch {
+			case fields != nil:
+				fieldType, known := fields[key]
+				if !known {
+					// the key token has just been read; point at its opening quote
+					end := decoder.InputOffset()
+					report(key, int64(bytes.LastIndexByte(data[:end-1], '"')), sortedKeys(fields))
+				}
+				valueType = fieldType
+			case t != nil && t.Kind() == reflect.Map:
+				valueType = t.Elem()
+			}
+
+			if err := walkJSONValue(decoder, data, valueType, report); err != nil {
+				return err
+			}
+		}
+	case '[':
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		for decoder.More() {
+			if err := walkJSONValue(decoder, data, elemType, report); err != nil {
+				return err
+			}
+		}
+	}
+
+	// consume the closing delimiter
+	_, err = decoder.Token()
+	return err
+}
+
+// jsonFields maps the JSON names of a struct's fields to their types.
+func jsonFields(t reflect.Type) map[string]reflect.Type {
+	fields := make(map[string]reflect.Type, t.NumField())
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, _, _ := strings.Cut(field.Tag.Get("json"), ",")
+		if name == "-" || !field.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = field.Name
+		}
+		fields[name] = field.Type
+	}
+	return fields
+}
+
+func sortedKeys[V any](fields map[string]V) []string {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// positionedError prefixes JSON syntax and type errors with the file, line and column they occurred at.
+func positionedError(filename string, data []byte, err error) error {
+	var syntaxError *json.SyntaxError
+	var typeError *json.UnmarshalTypeError
+
+	switch {
+	case errors.As(err, &syntaxError):
+		line, column := lineAndColumn(data, syntaxError.Offset)
+		return fmt.Errorf("%s:%d:%d: %w", filename, line, column, err)
+	case errors.As(err, &typeError):
+		line, column := lineAndColumn(data, typeError.Offset)
+		return fmt.Errorf("%s:%d:%d: %w", filename, line, column, err)
+	}
+
+	return fmt.Errorf("%s: %w", filename, err)
+}
+
+// lineAndColumn converts a byte offset into a 1-based line and column.
+func lineAndColumn(data []byte, offset int64) (int, int) {
+	if offset < 0 {
+		offset = 0
+	}
+	if offset > int64(len(data)) {
+		offset = int64(len(data))
+	}
+
+	before := data[:offset]
+	line := bytes.Count(before, []byte("\n")) + 1
+	column := int(offset) - bytes.LastIndexByte(before, '\n')
+	return line, column
+}
+
+// closestKey suggests the known key nearest to a misspelled one, if any is close enough.
+func closestKey(key string, known []string) string {
+	best := ""
+	bestDistance := len(key)/2 + 1
+
+	for _, candidate := range known {
+		distance := editDistance(strings.ToLower(key), strings.ToLower(candidate))
+		if distance < bestDistance {
+			best = candidate
+			bestDistance = distance
+		}
+	}
+
+	return best
+}
+
+// editDistance computes the Levenshtein distance between two strings.
+func editDistance(a, b string) int {
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, previous[j-1]+cost)
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
@@ -0,0 +1,134 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// writeConfig writes a configuration file into a temporary directory and returns its path.
+func writeConfig(t *testing.T, filename string, content string) string {
+	t.Helper()
+
+	configPath := filepath.Join(t.TempDir(), filename)
+	if err := os.WriteFile(configPath, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return configPath
+}
+
+func TestLoadSettingsAcceptsSchemaKey(t *testing.T) {
+	configs := map[string]string{
+		"exepy.json": `{"$schema": "./exepy.schema.json", "configVersion": 1, "applicationName": "App"}`,
+		"exepy.yaml": "$schema: ./exepy.schema.json\nconfigVersion: 1\napplicationName: App\n",
+		"exepy.toml": "\"$schema\" = \"./exepy.schema.json\"\nconfigVersion = 1\napplicationName = \"App\"\n",
+	}
+
+	for filename, content := range configs {
+		t.Run(filename, func(t *testing.T) {
+			settings, err := loadSettings(writeConfig(t, filename, content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if settings.Schema == nil || *settings.Schema != "./exepy.schema.json" {
+				t.Errorf("expected $schema to be kept, got %v", settings.Schema)
+			}
+			if settings.ApplicationName == nil || *settings.ApplicationName != "App" {
+				t.Errorf("expected applicationName App, got %v", settings.ApplicationName)
+			}
+		})
+	}
+}
+
+func TestSettingsJSONSchemaAllowsSchemaKey(t *testing.T) {
+	data, err := SettingsJSONSchema()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var schema struct {
+		Properties map[string]json.RawMessage `json:"properties"`
+	}
+	if err := json.Unmarshal(data, &schema); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, ok := schema.Properties["$schema"]; !ok {
+		t.Error("the schema does not allow a $schema property")
+	}
+}
+
+func TestDecodeSettingsMigratesVersion0(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		migrated bool
+		version  string
+		arch     string
+	}{
+		{
+			name:     "python version from the download URL",
+			config:   `{"pythonDownloadURL": "https://www.python.org/ftp/python/3.10.11/python-3.10.11-embed-win32.zip"}`,
+			migrated: true,
+			version:  "3.10.11",
+			arch:     "win32",
+		},
+		{
+			name:     "explicit python version is kept",
+			config:   `{"pythonVersion": "3.12.4", "pythonDownloadURL": "https://mirror.example.com/python-3.10.11-embed-amd64.zip"}`,
+			migrated: true,
+			version:  "3.12.4",
+			arch:     "amd64",
+		},
+		{
+			name:   "download URL of a custom build",
+			config: `{"pythonDownloadURL": "https://example.com/custom-python.zip"}`,
+		},
+		{
+			name:   "no download URL",
+			config: `{"applicationName": "App"}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings, migrated, err := decodeSettings("exepy.json", []byte(test.config))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if migrated != test.migrated {
+				t.Errorf("expected migrated to be %v", test.migrated)
+			}
+			if !test.migrated {
+				if settings.ConfigVersion != nil || settings.PythonVersion != nil {
+					t.Errorf("expected the settings to be left alone, got configVersion %v and pythonVersion %v", settings.ConfigVersion, settings.PythonVersion)
+				}
+				return
+			}
+
+			if settings.ConfigVersion == nil || *settings.ConfigVersion != CurrentConfigVersion {
+				t.Errorf("expected configVersion %d, got %v", CurrentConfigVersion, settings.ConfigVersion)
+			}
+			if settings.PythonVersion == nil || *settings.PythonVersion != test.version {
+				t.Errorf("expected pythonVersion %s, got %v", test.version, settings.PythonVersion)
+			}
+			if settings.Arch == nil || *settings.Arch != test.arch {
+				t.Errorf("expected arch %s, got %v", test.arch, settings.Arch)
+			}
+		})
+	}
+}
+
+func TestDecodeSettingsDoesNotMigrateCurrentVersion(t *testing.T) {
+	config := `{"configVersion": 1, "pythonDownloadURL": "https://www.python.org/ftp/python/3.10.11/python-3.10.11-embed-win32.zip"}`
+
+	settings, migrated, err := decodeSettings("exepy.json", []byte(config))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if migrated || settings.PythonVersion != nil || settings.Arch != nil {
+		t.Errorf("expected a configVersion 1 file to be left alone, got pythonVersion %v and arch %v", settings.PythonVersion, settings.Arch)
+	}
+}
@@ -0,0 +1,244 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v3"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+)
+
+const (
+	formatJSON = "json"
+	formatYAML = "yaml"
+	formatTOML = "toml"
+)
+
+// ConfigFileNames lists the configuration files looked for when no file is given, in order of preference.
+var ConfigFileNames = []string{"exepy.json", "exepy.yaml", "exepy.yml", "exepy.toml"}
+
+// FindConfigFile returns the configuration file in dir. Having more than one is an error,
+// since it would be unclear which one applies. If there is none, the first name in ConfigFileNames is returned.
+func FindConfigFile(dir string) (string, error) {
+	var found []string
+	for _, name := range ConfigFileNames {
+		if DoesPathExist(filepath.Join(dir, name)) {
+			found = append(found, name)
+		}
+	}
+
+	switch len(found) {
+	case 0:
+		return filepath.Join(dir, ConfigFileNames[0]), nil
+	case 1:
+		return filepath.Join(dir, found[0]), nil
+	}
+
+	return "", fmt.Errorf("found several configuration files (%s); remove all but one or select one with --config", strings.Join(found, ", "))
+}
+
+// configFormat determines the format of a configuration file from its extension. JSON is the default.
+func configFormat(filename string) string {
+	switch strings.ToLower(filepath.Ext(filename)) {
+	case ".yaml", ".yml":
+		return formatYAML
+	case ".toml":
+		return formatTOML
+	}
+	return formatJSON
+}
+
+// encodeSettings serializes settings in the format of the given file name.
+func encodeSettings(filename string, settings *PythonSetupSettings) ([]byte, error) {
+	data, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil || configFormat(filename) == formatJSON {
+		return data, err
+	}
+
+	// YAML and TOML have no struct tags on the settings, so go through the JSON representation.
+	var config map[string]any
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+	for key, value := range config {
+		switch v := value.(type) {
+		case nil:
+			// TOML cannot represent null
+			delete(config, key)
+		case float64:
+			// JSON numbers decode as floats; keep integers such as configVersion integral
+			if v == float64(int64(v)) {
+				config[key] = int64(v)
+			}
+		}
+	}
+
+	if configFormat(filename) == formatYAML {
+		return yaml.Marshal(config)
+	}
+
+	var buffer strings.Builder
+	if err := toml.NewEncoder(&buffer).Encode(config); err != nil {
+		return nil, err
+	}
+	return []byte(buffer.String()), nil
+}
+
+// yamlToJSON converts a YAML configuration to JSON, reporting unknown keys with their line and column.
+func yamlToJSON(filename string, data []byte, t reflect.Type) ([]byte, error) {
+	var document yaml.Node
+	if err := yaml.Unmarshal(data, &document); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	if len(document.Content) == 0 {
+		return []byte("{}"), nil
+	}
+	root := document.Content[0]
+
+	var unknown []error
+	walkYAMLNode(root, t, func(key *yaml.Node, known []string) {
+		unknown = append(unknown, fmt.Errorf("%s:%d:%d: %s", filename, key.Line, key.Column, unknownKeyMessage(key.Value, known)))
+	})
+	if len(unknown) > 0 {
+		return nil, errors.Join(unknown...)
+	}
+
+	var config any
+	if err := root.Decode(&config); err != nil {
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	return json.Marshal(config)
+}
+
+// walkYAMLNode reports the mapping keys below node that do not correspond to a field of t.
+func walkYAMLNode(node *yaml.Node, t reflect.Type, report func(key *yaml.Node, known []string)) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch node.Kind {
+	case yaml.MappingNode:
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+
+		for i := 0; i+1 < len(node.Content); i += 2 {
+			key, value := node.Content[i], node.Content[i+1]
+
+			var valueType reflect.Type
+			switch {
+			case fields != nil:
+				fieldType, known := fields[key.Value]
+				if !known {
+					report(key, sortedKeys(fields))
+				}
+				valueType = fieldType
+			case t != nil && t.Kind() == reflect.Map:
+				valueType = t.Elem()
+			}
+
+			walkYAMLNode(value, valueType, report)
+		}
+	case yaml.SequenceNode:
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		for _, item := range node.Content {
+			walkYAMLNode(item, elemType, report)
+		}
+	}
+}
+
+// tomlToJSON converts a TOML configuration to JSON, reporting unknown keys by their dotted path.
+// The TOML decoder does not expose key positions.
+func tomlToJSON(filename string, data []byte, t reflect.Type) ([]byte, error) {
+	var config map[string]any
+	if _, err := toml.Decode(string(data), &config); err != nil {
+		var parseError toml.ParseError
+		if errors.As(err, &parseError) {
+			return nil, fmt.Errorf("%s:%d:%d: %s", filename, parseError.Position.Line, parseError.Position.Col, parseError.Message)
+		}
+		return nil, fmt.Errorf("%s: %w", filename, err)
+	}
+
+	var unknown []error
+	walkGenericValue(config, t, "", func(keyPath string, key string, known []string) {
+		unknown = append(unknown, fmt.Errorf("%s: %s: %s", filename, keyPath, unknownKeyMessage(key, known)))
+	})
+	if len(unknown) > 0 {
+		return nil, errors.Join(unknown...)
+	}
+
+	return json.Marshal(config)
+}
+
+// walkGenericValue reports the map keys below value that do not correspond to a field of t.
+func walkGenericValue(value any, t reflect.Type, keyPath string, report func(keyPath string, key string, known []string)) {
+	for t != nil && t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch v := value.(type) {
+	case map[string]any:
+		var fields map[string]reflect.Type
+		if t != nil && t.Kind() == reflect.Struct {
+			fields = jsonFields(t)
+		}
+
+		keys := make([]string, 0, len(v))
+		for key := range v {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+
+		for _, key := range keys {
+			item := v[key]
+			itemPath := key
+			if keyPath != "" {
+				itemPath = keyPath + "." + key
+			}
+
+			var itemType reflect.Type
+			switch {
+			case fields != nil:
+				fieldType, known := fields[key]
+				if !known {
+					report(itemPath, key, sortedKeys(fields))
+				}
+				itemType = fieldType
+			case t != nil && t.Kind() == reflect.Map:
+				itemType = t.Elem()
+			}
+
+			walkGenericValue(item, itemType, itemPath, report)
+		}
+	case []any:
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		for i, item := range v {
+			walkGenericValue(item, elemType, fmt.Sprintf("%s[%d]", keyPath, i), report)
+		}
+	case []map[string]any:
+		// arrays of tables
+		var elemType reflect.Type
+		if t != nil && (t.Kind() == reflect.Slice || t.Kind() == reflect.Array) {
+			elemType = t.Elem()
+		}
+
+		for i, item := range v {
+			walkGenericValue(item, elemType, fmt.Sprintf("%s[%d]", keyPath, i), report)
+		}
+	}
+}
@@ -0,0 +1,201 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// EnvPrefix prefixes the environment variables that override settings, e.g. EXEPY_APPLICATION_NAME.
+const EnvPrefix = "EXEPY_"
+
+// SettingOverride replaces a single setting after the configuration file is loaded.
+//
+// Overrides are applied on top of the configuration file in order, so later overrides win.
+// Environment variables are collected before --set overrides, giving the precedence
+// defaults < configuration file < EXEPY_* variables < --set.
+type SettingOverride struct {
+	// Source names where the override came from, for error messages.
+	Source string
+	// Key is the JSON name of the setting.
+	Key   string
+	Value string
+}
+
+// EnvOverrides collects the overrides given by EXEPY_* variables in environ, which is in the format of os.Environ.
+// The variable name is the setting's name in upper snake case, e.g. EXEPY_RUN_AFTER_INSTALL for runAfterInstall.
+func EnvOverrides(environ []string) []SettingOverride {
+	keys := make(map[string]string)
+	for key := range jsonFields(reflect.TypeOf(PythonSetupSettings{})) {
+		keys[EnvPrefix+envName(key)] = key
+	}
+
+	var overrides []SettingOverride
+	for _, variable := range environ {
+		name, value, _ := strings.Cut(variable, "=")
+		if !strings.HasPrefix(name, EnvPrefix) {
+			continue
+		}
+
+		key, ok := keys[name]
+		if !ok {
+			continue
+		}
+
+		overrides = append(overrides, SettingOverride{Source: name, Key: key, Value: value})
+	}
+
+	// os.Environ has no defined order; sort for reproducible error messages
+	sort.Slice(overrides, func(i, j int) bool {
+		return overrides[i].Source < overrides[j].Source
+	})
+
+	return overrides
+}
+
+// ParseSetOverrides parses "key=value" arguments of the --set flag. Keys are matched case-insensitively.
+func ParseSetOverrides(values []string) ([]SettingOverride, error) {
+	fields := jsonFields(reflect.TypeOf(PythonSetupSettings{}))
+
+	overrides := make([]SettingOverride, 0, len(values))
+	for _, value := range values {
+		key, setting, found := strings.Cut(value, "=")
+		if !found {
+			return nil, fmt.Errorf("invalid --set %q: expected key=value", value)
+		}
+
+		key = strings.TrimSpace(key)
+		name := ""
+		for field := range fields {
+			if strings.EqualFold(field, key) {
+				name = field
+			}
+		}
+		if name == "" {
+			return nil, fmt.Errorf("invalid --set %q: %s", value, unknownKeyMessage(key, sortedKeys(fields)))
+		}
+
+		overrides = append(overrides, SettingOverride{Source: "--set " + name, Key: name, Value: setting})
+	}
+
+	return overrides, nil
+}
+
+// applyOverrides replaces the overridden settings, converting each value to the setting's type.
+//
+// Strings are used as they are. Booleans and integers are parsed. Lists are either JSON arrays
+// or comma-separated values, so payload mappings can be given in their "source -> destination" form.
+func applyOverrides(settings *PythonSetupSettings, overrides []SettingOverride) (*PythonSetupSettings, error) {
+	if len(overrides) == 0 {
+		return settings, nil
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+
+	var config map[string]json.RawMessage
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, err
+	}
+
+	fields := jsonFields(reflect.TypeOf(PythonSetupSettings{}))
+
+	for _, override := range overrides {
+		value, err := overrideValue(override, fields[override.Key])
+		if err != nil {
+			return nil, err
+		}
+		config[override.Key] = value
+	}
+
+	if data, err = json.Marshal(config); err != nil {
+		return nil, err
+	}
+
+	var overridden PythonSetupSettings
+	if err := json.Unmarshal(data, &overridden); err != nil {
+		return nil, fmt.Errorf("error applying overrides: %w", err)
+	}
+
+	return &overridden, nil
+}
+
+// overrideValue converts the text of an override to the JSON value of a setting of type t.
+func overrideValue(override SettingOverride, t reflect.Type) (json.RawMessage, error) {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		value, err := strconv.ParseBool(strings.TrimSpace(override.Value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not a boolean", override.Source, override.Value)
+		}
+		return json.Marshal(value)
+	case reflect.Int:
+		value, err := strconv.Atoi(strings.TrimSpace(override.Value))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %q is not an integer", override.Source, override.Value)
+		}
+		return json.Marshal(value)
+	case reflect.Map:
+		trimmed := strings.TrimSpace(override.Value)
+		if !strings.HasPrefix(trimmed, "{") {
+			return nil, fmt.Errorf("%s: %q is not a JSON object", override.Source, override.Value)
+		}
+		if err := checkUnknownKeys(override.Source, []byte(trimmed), t); err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal([]byte(trimmed), reflect.New(t).Interface()); err != nil {
+			return nil, positionedError(override.Source, []byte(trimmed), err)
+		}
+		return json.RawMessage(trimmed), nil
+	case reflect.Slice:
+		trimmed := strings.TrimSpace(override.Value)
+		if strings.HasPrefix(trimmed, "[") {
+			if err := checkUnknownKeys(override.Source, []byte(trimmed), t); err != nil {
+				return nil, err
+			}
+			if err := json.Unmarshal([]byte(trimmed), reflect.New(t).Interface()); err != nil {
+				return nil, positionedError(override.Source, []byte(trimmed), err)
+			}
+			return json.RawMessage(trimmed), nil
+		}
+
+		items := []string{}
+		if trimmed != "" {
+			for _, item := range strings.Split(trimmed, ",") {
+				items = append(items, strings.TrimSpace(item))
+			}
+		}
+		return json.Marshal(items)
+	}
+
+	return json.Marshal(override.Value)
+}
+
+// envName converts a camelCase setting name to upper snake case, e.g. pythonDownloadURL to PYTHON_DOWNLOAD_URL.
+func envName(key string) string {
+	runes := []rune(key)
+
+	var name strings.Builder
+	for i, r := range runes {
+		if i > 0 && unicode.IsUpper(r) {
+			previousLower := unicode.IsLower(runes[i-1])
+			nextLower := i+1 < len(runes) && unicode.IsLower(runes[i+1])
+			if previousLower || (unicode.IsUpper(runes[i-1]) && nextLower) {
+				name.WriteByte('_')
+			}
+		}
+		name.WriteRune(unicode.ToUpper(r))
+	}
+
+	return name.String()
+}
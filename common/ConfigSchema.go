@@ -0,0 +1,141 @@
+package common
+
+import (
+	"encoding/json"
+	"reflect"
+)
+
+// settingDescriptions documents each configuration key in the generated JSON Schema.
+var settingDescriptions = map[string]string{
+	"$schema":               "JSON Schema of the configuration, for editors. Exepy ignores it.",
+	"configVersion":         "Version of the configuration format. Older versions are migrated automatically.",
+	"applicationName":       "Name of the application, used for the default install location.",
+	"version":               "Version of the application, e.g. 1.2.0.",
+	"publisher":             "Publisher of the application.",
+	"description":           "Short description of the application.",
+	"appId":                 "Identifier that distinguishes this application from others installed in the same directory. Defaults to applicationName.",
+	"versionPolicy":         "What an installer does when another version is installed: upgrade, refuseDowngrade or sideBySide.",
+	"outputName":            "File name of the built installer. {{key}} is replaced by the value of a setting, e.g. {{applicationName}}-{{version}}-setup.exe.",
+	"runScriptFileStem":     "File name, without extension, of the generated script and launcher that run the application.",
+	"launcher":              "Native launcher placed in the install root: console, windowed (no console window) or none.",
+	"pythonVersion":         "Version of Python to package, e.g. 3.12.4. The download URL and distribution file names are derived from it.",
+	"arch":                  "Architecture of the Python distribution: amd64, win32 or arm64.",
+	"pythonDownloadURL":     "URL of the Python embeddable distribution to package. Derived from pythonVersion and arch by default.",
+	"pipDownloadURL":        "URL of the pip zipapp used to install requirements.",
+	"pythonSha256":          "Expected SHA-256 of the Python download. The build fails if the download does not match.",
+	"pipSha256":             "Expected SHA-256 of the pip download. The build fails if the download does not match.",
+	"downloadProxy":         "URL of the proxy used for downloads. Empty uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.",
+	"downloadCAFile":        "PEM file of additional certificate authorities trusted for downloads, e.g. those of a corporate proxy.",
+	"pythonDownloadFile":    "File name the Python distribution is downloaded to. Derived from pythonVersion and arch by default.",
+	"pythonExtractDir":      "Directory, relative to the install root, that Python is extracted to.",
+	"scriptExtractDir":      "Directory, relative to the install root, that the scripts are extracted to.",
+	"installDir":            "Default install root. Environment variables are expanded; empty selects a per-user location.",
+	"pthFile":               "Name of the ._pth file of the Python distribution, e.g. python311._pth. Derived from pythonVersion by default.",
+	"pythonInteriorZip":     "Name of the standard library zip of the Python distribution, e.g. python311.zip. Derived from pythonVersion by default.",
+	"pythonPaths":           "Additional module search paths, relative to the install root, e.g. scripts or vendor.",
+	"pythonIsolated":        "Do not import the site module at startup, so .pth files in site-packages and sitecustomize.py are ignored.",
+	"siteCustomize":         "Python file whose contents are appended to the generated sitecustomize.py.",
+	"installerRequirements": "Requirements file installed before the setup script runs.",
+	"requirementsFile":      "Requirements file of the application, relative to scriptDir.",
+	"scriptDir":             "Directory containing the application scripts.",
+	"setupScript":           "Script, relative to scriptDir, run once after installation.",
+	"mainScript":            "Script, relative to scriptDir, that starts the application.",
+	"entryPoints":           "Launchers to install, by name. Each is the target, a script relative to scriptDir or module:function, or an object with target or module, args, env and launcher. Defaults to mainScript, named after runScriptFileStem.",
+	"defaultEntryPoint":     "Entry point started by runAfterInstall when there are several.",
+	"mainModule":            "Module, importable from scriptDir, run like \"python -m module\" instead of mainScript.",
+	"pythonFlags":           "Interpreter options the application is started with, e.g. [\"-X\", \"utf8\", \"-u\"].",
+	"env":                   "Environment variables set for the application.",
+	"workingDirectory":      "Working directory of the application: inherit (the caller's), installRoot or scriptDir.",
+	"filesToCopyToRoot":     "Files copied into the install root.",
+	"payload":               "Additional files to package, as \"source -> destination\" strings or objects.",
+	"runAfterInstall":       "Run the application once installation completes.",
+	"onlineRequirements":    "Install requirements from the package index at install time instead of embedding wheels.",
+	"pipIsolated":           "Make the installer's pip ignore the pip configuration, PIP_* variables, cache and user site-packages of the machine.",
+	"pipOptions":            "Additional options for every pip install run by the installer, e.g. [\"--index-url\", \"https://pypi.example.com/simple\"].",
+	"smokeTestArgs":         "Arguments passed to the default entry point by \"exepy build --test\", e.g. [\"--self-test\"].",
+	"smokeTestScript":       "Script relative to scriptDir that \"exepy build --test\" runs instead of the default entry point.",
+	"smokeTestOutput":       "Regular expression the output of the smoke test must match.",
+	"ignoredPathParts":      "Gitignore-style patterns excluded from the packaged scripts.",
+	"integrityIgnore":       "Gitignore-style patterns not reported as unexpected files by integrity checks.",
+}
+
+// SettingsJSONSchema returns a JSON Schema describing the configuration file, for editor validation and completion.
+func SettingsJSONSchema() ([]byte, error) {
+	schema := schemaForType(reflect.TypeOf(PythonSetupSettings{}))
+	schema["$schema"] = "https://json-schema.org/draft/2020-12/schema"
+	schema["title"] = "Exepy configuration"
+
+	return json.MarshalIndent(schema, "", "  ")
+}
+
+func schemaForType(t reflect.Type) map[string]any {
+	for t.Kind() == reflect.Pointer {
+		t = t.Elem()
+	}
+
+	if t == reflect.TypeOf(PayloadMapping{}) {
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string", "pattern": payloadArrow},
+				schemaForStruct(t),
+			},
+		}
+	}
+
+	if t == reflect.TypeOf(EntryPoint{}) {
+		return map[string]any{
+			"oneOf": []any{
+				map[string]any{"type": "string"},
+				schemaForStruct(t),
+			},
+		}
+	}
+
+	switch t.Kind() {
+	case reflect.Struct:
+		return schemaForStruct(t)
+	case reflect.Slice, reflect.Array:
+		return map[string]any{"type": "array", "items": schemaForType(t.Elem())}
+	case reflect.Map:
+		return map[string]any{"type": "object", "additionalProperties": schemaForType(t.Elem())}
+	case reflect.Bool:
+		return map[string]any{"type": "boolean"}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64,
+		reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return map[string]any{"type": "integer"}
+	case reflect.Float32, reflect.Float64:
+		return map[string]any{"type": "number"}
+	default:
+		return map[string]any{"type": "string"}
+	}
+}
+
+func schemaForStruct(t reflect.Type) map[string]any {
+	properties := make(map[string]any)
+
+	for name, fieldType := range jsonFields(t) {
+		property := schemaForType(fieldType)
+		if name == "versionPolicy" && t == reflect.TypeOf(PythonSetupSettings{}) {
+			property["enum"] = VersionPolicies
+		}
+		if name == "arch" && t == reflect.TypeOf(PythonSetupSettings{}) {
+			property["enum"] = PythonArchitectures
+		}
+		if name == "launcher" && t == reflect.TypeOf(PythonSetupSettings{}) {
+			property["enum"] = LauncherModes
+		}
+		if name == "workingDirectory" && t == reflect.TypeOf(PythonSetupSettings{}) {
+			property["enum"] = WorkingDirectoryPolicies
+		}
+		if description, ok := settingDescriptions[name]; ok && t == reflect.TypeOf(PythonSetupSettings{}) {
+			property["description"] = description
+		}
+		properties[name] = property
+	}
+
+	return map[string]any{
+		"type":                 "object",
+		"properties":           properties,
+		"additionalProperties": false,
+	}
+}
@@ -0,0 +1,114 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ValidateSemantics checks that the merged settings are consistent with each other and with the files on disk.
+// Relative paths are resolved against baseDir. Every problem found is reported, not just the first.
+func (s *PythonSetupSettings) ValidateSemantics(baseDir string) error {
+	var problems []error
+
+	problems = append(problems, s.validatePythonDistribution()...)
+
+	checkRelative := func(field string, value string) bool {
+		if err := checkRelativePath(value); err != nil {
+			problems = append(problems, fmt.Errorf("%s %q %w", field, value, err))
+			return false
+		}
+		return true
+	}
+
+	checkExists := func(field string, dir string, value string) {
+		if value == "" || !checkRelative(field, value) {
+			return
+		}
+		if !DoesPathExist(filepath.Join(baseDir, dir, value)) {
+			problems = append(problems, fmt.Errorf("%s %q does not exist", field, filepath.Join(dir, value)))
+		}
+	}
+
+	if _, err := ExpandOutputName(*s.OutputName, s); err != nil {
+		problems = append(problems, err)
+	}
+
+	checkSha256 := func(field string, value string) {
+		if value != "" && !isSha256Hex(value) {
+			problems = append(problems, fmt.Errorf("%s %q is not a hex-encoded SHA-256 digest", field, value))
+		}
+	}
+
+	checkSha256("pythonSha256", *s.PythonSha256)
+	checkSha256("pipSha256", *s.PipSha256)
+
+	if *s.DownloadProxy != "" {
+		if proxyURL, err := url.Parse(*s.DownloadProxy); err != nil || proxyURL.Host == "" {
+			problems = append(problems, fmt.Errorf("downloadProxy %q is not a URL like http://proxy:8080", *s.DownloadProxy))
+		}
+	}
+
+	if caFile := *s.DownloadCAFile; caFile != "" {
+		if !filepath.IsAbs(caFile) {
+			caFile = filepath.Join(baseDir, caFile)
+		}
+		if !DoesPathExist(caFile) {
+			problems = append(problems, fmt.Errorf("downloadCAFile %q does not exist", *s.DownloadCAFile))
+		}
+	}
+
+	checkRelative("pythonExtractDir", *s.PythonExtractDir)
+	checkRelative("scriptExtractDir", *s.ScriptExtractDir)
+
+	checkExists("scriptDir", "", *s.ScriptDir)
+	// mainModule takes the place of mainScript
+	if *s.MainModule == "" {
+		checkExists("mainScript", *s.ScriptDir, *s.MainScript)
+	} else if !modulePattern.MatchString(*s.MainModule) {
+		problems = append(problems, fmt.Errorf("mainModule %q is not a module name", *s.MainModule))
+	}
+	checkExists("requirementsFile", *s.ScriptDir, *s.RequirementsFile)
+	checkExists("setupScript", *s.ScriptDir, *s.SetupScript)
+	checkExists("installerRequirements", "", *s.InstallerRequirements)
+	checkExists("siteCustomize", "", *s.SiteCustomize)
+	checkExists("smokeTestScript", *s.ScriptDir, *s.SmokeTestScript)
+
+	if _, err := regexp.Compile(*s.SmokeTestOutput); err != nil {
+		problems = append(problems, fmt.Errorf("smokeTestOutput is not a valid regular expression: %w", err))
+	}
+
+	for _, searchPath := range s.PythonPaths {
+		checkRelative("pythonPaths entry", searchPath)
+	}
+
+	if *s.PythonIsolated && *s.SiteCustomize != "" {
+		problems = append(problems, errors.New("siteCustomize has no effect when pythonIsolated is set, because sitecustomize.py is only run by the site module"))
+	}
+
+	problems = append(problems, s.validateEntryPoints(baseDir)...)
+
+	for _, file := range s.FilesToCopyToRoot {
+		checkExists("filesToCopyToRoot entry", "", file)
+	}
+
+	return errors.Join(problems...)
+}
+
+// checkRelativePath rejects absolute paths and paths that climb out of their base directory.
+func checkRelativePath(value string) error {
+	if filepath.IsAbs(value) || path.IsAbs(filepath.ToSlash(value)) || filepath.VolumeName(value) != "" {
+		return errors.New("must be a relative path")
+	}
+
+	cleaned := path.Clean(filepath.ToSlash(value))
+	if cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return errors.New("must not point outside the project directory")
+	}
+
+	return nil
+}
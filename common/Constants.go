@@ -8,6 +8,8 @@ const ScriptIntegrityFilename = "scripts_integrity"
 const WheelsFolderName = "wheels"
 const HashmapName = "hashmap"
 const CopyToRootFilename = "copy_to_root"
+const InstallDigestsFilename = "install_digests"
+const PayloadFilename = "payload"
 
 const pipFilename = "pip.pyz"
 
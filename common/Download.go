@@ -0,0 +1,317 @@
+package common
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DownloadOptions configures how a Downloader connects.
+type DownloadOptions struct {
+	// Proxy is the URL of the proxy to use; empty uses the HTTP_PROXY, HTTPS_PROXY and NO_PROXY environment variables.
+	Proxy string
+	// CAFile is a PEM file of additional certificate authorities to trust, e.g. those of a corporate proxy.
+	CAFile string
+}
+
+// Downloader fetches files over HTTP. Failed transfers are retried with exponential backoff and resumed
+// where they stopped when the server supports range requests.
+type Downloader struct {
+	Client *http.Client
+	// Retries is the number of attempts made after the first one fails.
+	Retries int
+	// Backoff is the delay before the first retry; it doubles with every further retry.
+	Backoff time.Duration
+	// StallTimeout aborts an attempt that receives no data for this long.
+	StallTimeout time.Duration
+	// Progress receives progress reports; nil disables them.
+	Progress io.Writer
+}
+
+// StatusError reports a response whose status is not a success.
+type StatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("downloading %s failed: %s", e.URL, e.Status)
+}
+
+// retryable reports whether the status may succeed when requested again.
+func (e *StatusError) retryable() bool {
+	return e.StatusCode >= 500 || e.StatusCode == http.StatusRequestTimeout || e.StatusCode == http.StatusTooManyRequests
+}
+
+// ChecksumError reports a downloaded file whose SHA-256 does not match the expected one.
+type ChecksumError struct {
+	URL      string
+	Expected string
+	Actual   string
+}
+
+func (e *ChecksumError) Error() string {
+	return fmt.Sprintf("checksum mismatch for %s: expected sha256 %s, got %s", e.URL, e.Expected, e.Actual)
+}
+
+// NewDownloader returns a Downloader with the default retry policy that connects as configured by options.
+func NewDownloader(options DownloadOptions) (*Downloader, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = (&net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}).DialContext
+	transport.TLSHandshakeTimeout = 15 * time.Second
+	transport.ResponseHeaderTimeout = 60 * time.Second
+
+	if options.Proxy != "" {
+		proxyURL, err := url.Parse(options.Proxy)
+		if err != nil {
+			return nil, fmt.Errorf("invalid proxy URL %q: %w", options.Proxy, err)
+		}
+		transport.Proxy = http.ProxyURL(proxyURL)
+	}
+
+	if options.CAFile != "" {
+		pem, err := os.ReadFile(options.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("error reading CA file: %w", err)
+		}
+
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("%s contains no PEM certificates", options.CAFile)
+		}
+
+		transport.TLSClientConfig = &tls.Config{RootCAs: pool}
+	}
+
+	return &Downloader{
+		Client:       &http.Client{Transport: transport},
+		Retries:      4,
+		Backoff:      time.Second,
+		StallTimeout: 60 * time.Second,
+		Progress:     os.Stdout,
+	}, nil
+}
+
+// DownloadFile downloads url to filePath with the default Downloader.
+func DownloadFile(url, filePath string) error {
+	downloader, err := NewDownloader(DownloadOptions{})
+	if err != nil {
+		return err
+	}
+	return downloader.Download(url, filePath, "")
+}
+
+// Download fetches url into filePath. When expectedSha256 is not empty the file must have that digest.
+// The data is written to filePath.part first, so filePath only ever holds a complete, verified download.
+func (d *Downloader) Download(url, filePath, expectedSha256 string) error {
+	partPath := filePath + ".part"
+
+	// a partial file left by an earlier run may belong to another URL, so only retries resume
+	os.Remove(partPath)
+
+	var err error
+	for attempt := 0; attempt <= d.Retries; attempt++ {
+		if attempt > 0 {
+			delay := d.Backoff << (attempt - 1)
+			fmt.Printf("Retrying download in %s (attempt %d of %d): %v\n", delay, attempt+1, d.Retries+1, err)
+			time.Sleep(delay)
+		}
+
+		err = d.attempt(url, partPath)
+		if err == nil {
+			break
+		}
+
+		var statusErr *StatusError
+		if errors.As(err, &statusErr) && !statusErr.retryable() {
+			break
+		}
+	}
+
+	if err != nil {
+		return err
+	}
+
+	if expectedSha256 != "" {
+		actual, err := Sha256SumFile(partPath)
+		if err != nil {
+			return err
+		}
+		if !strings.EqualFold(actual, expectedSha256) {
+			os.Remove(partPath)
+			return &ChecksumError{URL: url, Expected: strings.ToLower(expectedSha256), Actual: actual}
+		}
+	}
+
+	return os.Rename(partPath, filePath)
+}
+
+// attempt makes one request for url, continuing a partial download in partPath if there is one.
+func (d *Downloader) attempt(url, partPath string) error {
+	var offset int64
+	if info, err := os.Stat(partPath); err == nil {
+		offset = info.Size()
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	if offset > 0 {
+		request.Header.Set("Range", "bytes="+strconv.FormatInt(offset, 10)+"-")
+	}
+
+	client := d.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	response, err := client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+
+	flags := os.O_CREATE | os.O_WRONLY
+	switch {
+	case response.StatusCode == http.StatusPartialContent && offset > 0 && contentRangeStart(response) == offset:
+		flags |= os.O_APPEND
+	case response.StatusCode == http.StatusPartialContent:
+		// a range other than the one asked for cannot be appended
+		os.Remove(partPath)
+		return fmt.Errorf("cannot resume download of %s: unexpected Content-Range %q", url, response.Header.Get("Content-Range"))
+	case response.StatusCode == http.StatusRequestedRangeNotSatisfiable && offset > 0:
+		// the partial file does not fit the resource any more; start over on the next attempt
+		os.Remove(partPath)
+		return fmt.Errorf("cannot resume download of %s: %s", url, response.Status)
+	case response.StatusCode >= 200 && response.StatusCode < 300:
+		// the server sent the whole file
+		offset = 0
+		flags |= os.O_TRUNC
+	default:
+		return &StatusError{URL: url, StatusCode: response.StatusCode, Status: response.Status}
+	}
+
+	file, err := os.OpenFile(partPath, flags, 0644)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	total := int64(-1)
+	if response.ContentLength >= 0 {
+		total = offset + response.ContentLength
+	}
+
+	stallTimer := time.AfterFunc(d.stallTimeout(), cancel)
+	defer stallTimer.Stop()
+
+	progress := &progressWriter{
+		out:     d.Progress,
+		name:    path.Base(request.URL.Path),
+		written: offset,
+		total:   total,
+		onWrite: func() { stallTimer.Reset(d.stallTimeout()) },
+	}
+
+	written, err := io.Copy(file, io.TeeReader(response.Body, progress))
+	progress.finish()
+	if err != nil {
+		if ctx.Err() != nil {
+			return fmt.Errorf("download of %s stalled: no data received for %s", url, d.stallTimeout())
+		}
+		return err
+	}
+
+	if response.ContentLength >= 0 && written != response.ContentLength {
+		return fmt.Errorf("download of %s ended after %d of %d bytes", url, written, response.ContentLength)
+	}
+
+	return file.Close()
+}
+
+func (d *Downloader) stallTimeout() time.Duration {
+	if d.StallTimeout <= 0 {
+		return 60 * time.Second
+	}
+	return d.StallTimeout
+}
+
+// contentRangeStart returns the first byte position of a Content-Range header, or -1 if it has none.
+func contentRangeStart(response *http.Response) int64 {
+	var start, end int64
+	if _, err := fmt.Sscanf(response.Header.Get("Content-Range"), "bytes %d-%d/", &start, &end); err != nil {
+		return -1
+	}
+	return start
+}
+
+// progressWriter reports the progress of a download at most a few times a second.
+type progressWriter struct {
+	out         io.Writer
+	name        string
+	written     int64
+	total       int64
+	lastPrinted time.Time
+	onWrite     func()
+}
+
+func (p *progressWriter) Write(data []byte) (int, error) {
+	p.written += int64(len(data))
+	if p.onWrite != nil {
+		p.onWrite()
+	}
+
+	if p.out != nil && time.Since(p.lastPrinted) >= 250*time.Millisecond {
+		p.lastPrinted = time.Now()
+		p.print()
+	}
+
+	return len(data), nil
+}
+
+func (p *progressWriter) print() {
+	const mebibyte = 1024 * 1024
+	if p.total > 0 {
+		fmt.Fprintf(p.out, "\rDownloading %s: %.1f / %.1f MiB (%d%%)", p.name, float64(p.written)/mebibyte, float64(p.total)/mebibyte, p.written*100/p.total)
+	} else {
+		fmt.Fprintf(p.out, "\rDownloading %s: %.1f MiB", p.name, float64(p.written)/mebibyte)
+	}
+}
+
+func (p *progressWriter) finish() {
+	if p.out == nil || p.lastPrinted.IsZero() {
+		return
+	}
+	p.print()
+	fmt.Fprintln(p.out)
+}
+
+// isSha256Hex reports whether value looks like a hex-encoded SHA-256 digest.
+func isSha256Hex(value string) bool {
+	if len(value) != sha256.Size*2 {
+		return false
+	}
+	_, err := hex.DecodeString(value)
+	return err == nil
+}
@@ -0,0 +1,205 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+var downloadContent = []byte(strings.Repeat("embedded python ", 4096))
+
+func newTestDownloader(server *httptest.Server, retries int) *Downloader {
+	return &Downloader{
+		Client:       server.Client(),
+		Retries:      retries,
+		Backoff:      10 * time.Millisecond,
+		StallTimeout: 5 * time.Second,
+	}
+}
+
+func contentSha256(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// truncateResponse announces the whole content but sends only its first half before dropping the connection.
+func truncateResponse(t *testing.T, w http.ResponseWriter) {
+	w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)))
+	w.WriteHeader(http.StatusOK)
+	w.Write(downloadContent[:len(downloadContent)/2])
+	w.(http.Flusher).Flush()
+
+	conn, _, err := w.(http.Hijacker).Hijack()
+	if err != nil {
+		t.Errorf("hijacking connection: %v", err)
+		return
+	}
+	conn.Close()
+}
+
+func TestDownloadDoesNotRetryNotFound(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "python.zip")
+	err := newTestDownloader(server, 3).Download(server.URL+"/python.zip", filePath, "")
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusNotFound {
+		t.Fatalf("expected a 404 StatusError, got %v", err)
+	}
+	if n := requests.Load(); n != 1 {
+		t.Errorf("expected 1 request, got %d", n)
+	}
+	for _, leftover := range []string{filePath, filePath + ".part"} {
+		if DoesPathExist(leftover) {
+			t.Errorf("%s was left behind", leftover)
+		}
+	}
+}
+
+func TestDownloadRetriesServerErrorsWithBackoff(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) <= 2 {
+			http.Error(w, "busy", http.StatusServiceUnavailable)
+			return
+		}
+		w.Write(downloadContent)
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "python.zip")
+	downloader := newTestDownloader(server, 3)
+
+	start := time.Now()
+	if err := downloader.Download(server.URL+"/python.zip", filePath, contentSha256(downloadContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if n := requests.Load(); n != 3 {
+		t.Errorf("expected 3 requests, got %d", n)
+	}
+	// two retries wait the backoff and then twice the backoff
+	if elapsed, minimum := time.Since(start), 3*downloader.Backoff; elapsed < minimum {
+		t.Errorf("retries took %s, expected at least %s of backoff", elapsed, minimum)
+	}
+}
+
+func TestDownloadResumesWithRange(t *testing.T) {
+	var requests atomic.Int32
+	var resumedRange atomic.Value
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			truncateResponse(t, w)
+			return
+		}
+
+		rangeHeader := r.Header.Get("Range")
+		resumedRange.Store(rangeHeader)
+
+		var start int
+		if _, err := fmt.Sscanf(rangeHeader, "bytes=%d-", &start); err != nil {
+			t.Errorf("expected a Range request, got %q", rangeHeader)
+			return
+		}
+
+		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, len(downloadContent)-1, len(downloadContent)))
+		w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)-start))
+		w.WriteHeader(http.StatusPartialContent)
+		w.Write(downloadContent[start:])
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "python.zip")
+	if err := newTestDownloader(server, 2).Download(server.URL+"/python.zip", filePath, contentSha256(downloadContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	if want := fmt.Sprintf("bytes=%d-", len(downloadContent)/2); resumedRange.Load() != want {
+		t.Errorf("expected Range %q, got %v", want, resumedRange.Load())
+	}
+}
+
+func TestDownloadRestartsWhenRangeIsIgnored(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if requests.Add(1) == 1 {
+			truncateResponse(t, w)
+			return
+		}
+
+		// the server ignores the Range header and sends everything again
+		w.Write(downloadContent)
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "python.zip")
+	if err := newTestDownloader(server, 2).Download(server.URL+"/python.zip", filePath, contentSha256(downloadContent)); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(data) != len(downloadContent) {
+		t.Errorf("expected %d bytes, got %d", len(downloadContent), len(data))
+	}
+}
+
+func TestDownloadRemovesPartialFileOnChecksumMismatch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write(downloadContent)
+	}))
+	defer server.Close()
+
+	filePath := filepath.Join(t.TempDir(), "python.zip")
+	err := newTestDownloader(server, 0).Download(server.URL+"/python.zip", filePath, contentSha256([]byte("other content")))
+
+	var checksumErr *ChecksumError
+	if !errors.As(err, &checksumErr) {
+		t.Fatalf("expected a ChecksumError, got %v", err)
+	}
+	for _, leftover := range []string{filePath, filePath + ".part"} {
+		if DoesPathExist(leftover) {
+			t.Errorf("%s was left behind", leftover)
+		}
+	}
+}
+
+func TestDownloadStallTimeout(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", strconv.Itoa(len(downloadContent)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(downloadContent[:1024])
+		w.(http.Flusher).Flush()
+
+		// send nothing more until the client gives up
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	downloader := newTestDownloader(server, 0)
+	downloader.StallTimeout = 50 * time.Millisecond
+
+	filePath := filepath.Join(t.TempDir(), "python.zip")
+	err := downloader.Download(server.URL+"/python.zip", filePath, "")
+	if err == nil || !strings.Contains(err.Error(), "stalled") {
+		t.Fatalf("expected a stall error, got %v", err)
+	}
+}
@@ -0,0 +1,159 @@
+package common
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"regexp"
+)
+
+// EntryPoint is a way of starting the application, installed as a launcher named after its key in entryPoints.
+//
+// In the configuration an entry point is either an object or the shorthand string of its target, e.g.
+// "entryPoints": {"myapp": "gui.py", "myapp-cli": {"target": "myapp.cli:main", "args": ["--verbose"]}, "myapp-tool": {"module": "myapp.tool"}}.
+type EntryPoint struct {
+	// Target is a script relative to scriptDir, or a function given as module:function.
+	Target string `json:"target,omitempty"`
+	// Module is a module run like "python -m module", as an alternative to Target.
+	Module string `json:"module,omitempty"`
+	// Args are passed before the arguments given to the launcher.
+	Args []string `json:"args,omitempty"`
+	// Env holds environment variables set for the application.
+	Env map[string]string `json:"env,omitempty"`
+	// Launcher is console or windowed; empty uses the launcher setting.
+	Launcher string `json:"launcher,omitempty"`
+}
+
+func (e *EntryPoint) UnmarshalJSON(data []byte) error {
+	var shorthand string
+	if err := json.Unmarshal(data, &shorthand); err == nil {
+		e.Target = shorthand
+		return nil
+	}
+
+	type entryPoint EntryPoint
+	return json.Unmarshal(data, (*entryPoint)(e))
+}
+
+// modulePattern matches a dotted module name.
+var modulePattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*$`)
+
+// entryPointFunctionPattern matches a module:function target.
+var entryPointFunctionPattern = regexp.MustCompile(`^([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*):([A-Za-z_][A-Za-z0-9_]*(?:\.[A-Za-z_][A-Za-z0-9_]*)*)$`)
+
+// Function splits a module:function target. ok is false when the target is a script.
+func (e EntryPoint) Function() (module string, function string, ok bool) {
+	match := entryPointFunctionPattern.FindStringSubmatch(e.Target)
+	if match == nil {
+		return "", "", false
+	}
+	return match[1], match[2], true
+}
+
+// ResolvedEntryPoints returns the entry points installers create launchers for.
+// Without an entryPoints setting, mainScript is the one entry point, named after runScriptFileStem.
+// The result is empty when the launcher setting is none.
+func (s *PythonSetupSettings) ResolvedEntryPoints() map[string]EntryPoint {
+	if *s.Launcher == LauncherNone {
+		return nil
+	}
+
+	if len(s.EntryPoints) > 0 {
+		return s.EntryPoints
+	}
+
+	main, ok := s.MainEntryPoint()
+	if !ok {
+		return nil
+	}
+
+	return map[string]EntryPoint{*s.RunScriptFileStem: main}
+}
+
+// MainEntryPoint returns the entry point of mainModule, or else of mainScript, which the run script starts.
+// ok is false when neither is set.
+func (s *PythonSetupSettings) MainEntryPoint() (entryPoint EntryPoint, ok bool) {
+	if *s.MainModule != "" {
+		return EntryPoint{Module: *s.MainModule}, true
+	}
+	if *s.MainScript != "" {
+		return EntryPoint{Target: *s.MainScript}, true
+	}
+	return EntryPoint{}, false
+}
+
+// EntryPointNames returns the names of the resolved entry points in sorted order.
+func (s *PythonSetupSettings) EntryPointNames() []string {
+	return sortedKeys(s.ResolvedEntryPoints())
+}
+
+// DefaultEntryPointName returns the entry point runAfterInstall starts: defaultEntryPoint, or the only entry point.
+// It returns "" when there is no single choice.
+func (s *PythonSetupSettings) DefaultEntryPointName() string {
+	if *s.DefaultEntryPoint != "" {
+		return *s.DefaultEntryPoint
+	}
+
+	if names := s.EntryPointNames(); len(names) == 1 {
+		return names[0]
+	}
+
+	return ""
+}
+
+// validateEntryPoints checks the entry point names and targets; scripts are resolved against scriptDir in baseDir.
+func (s *PythonSetupSettings) validateEntryPoints(baseDir string) []error {
+	var problems []error
+
+	for _, name := range sortedKeys(s.EntryPoints) {
+		entryPoint := s.EntryPoints[name]
+
+		if name == "" || sanitizeFileName(name) != name {
+			problems = append(problems, fmt.Errorf("entry point name %q cannot be used as a file name", name))
+		}
+
+		if entryPoint.Launcher != "" && entryPoint.Launcher != LauncherConsole && entryPoint.Launcher != LauncherWindowed {
+			problems = append(problems, fmt.Errorf("entry point %s: launcher %q is not one of %s, %s", name, entryPoint.Launcher, LauncherConsole, LauncherWindowed))
+		}
+
+		if entryPoint.Module != "" {
+			if entryPoint.Target != "" {
+				problems = append(problems, fmt.Errorf("entry point %s sets both target and module", name))
+			} else if !modulePattern.MatchString(entryPoint.Module) {
+				problems = append(problems, fmt.Errorf("entry point %s: %q is not a module name", name, entryPoint.Module))
+			}
+			continue
+		}
+
+		if entryPoint.Target == "" {
+			problems = append(problems, fmt.Errorf("entry point %s has no target", name))
+			continue
+		}
+
+		if _, _, ok := entryPoint.Function(); ok {
+			continue
+		}
+
+		if err := checkRelativePath(entryPoint.Target); err != nil {
+			problems = append(problems, fmt.Errorf("entry point %s: target %q %w", name, entryPoint.Target, err))
+			continue
+		}
+
+		if !DoesPathExist(filepath.Join(baseDir, *s.ScriptDir, entryPoint.Target)) {
+			problems = append(problems, fmt.Errorf("entry point %s: %q is neither a script in %s nor a module:function", name, entryPoint.Target, *s.ScriptDir))
+		}
+	}
+
+	if name := *s.DefaultEntryPoint; name != "" {
+		if _, found := s.ResolvedEntryPoints()[name]; !found {
+			problems = append(problems, fmt.Errorf("defaultEntryPoint %q is not an entry point", name))
+		}
+	}
+
+	if *s.RunAfterInstall && len(s.ResolvedEntryPoints()) > 1 && *s.DefaultEntryPoint == "" {
+		problems = append(problems, errors.New("runAfterInstall needs defaultEntryPoint to choose between several entry points"))
+	}
+
+	return problems
+}
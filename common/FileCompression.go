@@ -8,8 +8,9 @@ import (
 	"io"
 )
 
-func DirToStream(directoryPath string, ignoredDirs []string) (io.ReadSeeker, error) {
-	files, err := dirstream.BuildRelativeFileList(directoryPath, ignoredDirs)
+// DirToStream compresses the files in the directory that the matcher does not exclude.
+func DirToStream(directoryPath string, excludes *dirstream.IgnoreMatcher) (io.ReadSeeker, error) {
+	files, err := dirstream.BuildRelativeFileList(directoryPath, excludes)
 	if err != nil {
 		return nil, fmt.Errorf("failed to build file list: %w", err)
 	}
@@ -27,6 +28,23 @@ func FilesToStream(directoryPath string, files []string, flatPaths bool) (io.Rea
 		return nil, fmt.Errorf("failed to encode directory: %w", err)
 	}
 
+	return compressStream(encoderStream)
+}
+
+// EntriesToStream compresses the given files, storing each under its archive path.
+// Relative source paths are resolved against directoryPath.
+func EntriesToStream(directoryPath string, entries []dirstream.FileEntry) (io.ReadSeeker, error) {
+	encoder := dirstream.NewEncoder(directoryPath, dirstream.DefaultChunkSize)
+	encoderStream, err := encoder.EncodeEntries(entries)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode files: %w", err)
+	}
+
+	return compressStream(encoderStream)
+}
+
+// compressStream gzips an encoded stream into memory.
+func compressStream(encoderStream io.Reader) (io.ReadSeeker, error) {
 	var buf bytes.Buffer
 
 	gzipWriter := gzip.NewWriter(&buf)
@@ -45,6 +63,12 @@ func FilesToStream(directoryPath string, files []string, flatPaths bool) (io.Rea
 }
 
 func StreamToDir(IOReader io.Reader, outputDir string) error {
+	return StreamToDirFiltered(IOReader, outputDir, nil)
+}
+
+// StreamToDirFiltered extracts only the files for which include returns true.
+// include receives slash-separated paths relative to outputDir; a nil include extracts everything.
+func StreamToDirFiltered(IOReader io.Reader, outputDir string, include func(relativePath string) bool) error {
 	// Create a gzip reader from the input stream.
 	gzipReader, err := gzip.NewReader(IOReader)
 	if err != nil {
@@ -59,7 +83,7 @@ func StreamToDir(IOReader io.Reader, outputDir string) error {
 		return fmt.Errorf("failed to create decoder: %w", err)
 	}
 
-	if err := decoder.Decode(gzipReader); err != nil {
+	if err := decoder.DecodeFiltered(gzipReader, include); err != nil {
 		return fmt.Errorf("failed to decode stream: %w", err)
 	}
 
@@ -3,27 +3,9 @@ package common
 import (
 	"fmt"
 	"io"
-	"net/http"
 	"os"
 )
 
-func DownloadFile(url, filePath string) error {
-	response, err := http.Get(url)
-	if err != nil {
-		return err
-	}
-	defer response.Body.Close()
-
-	file, err := os.Create(filePath)
-	if err != nil {
-		return err
-	}
-	defer file.Close()
-
-	_, err = io.Copy(file, response.Body)
-	return err
-}
-
 func CopyFile(src, dst string) error {
 
 	from, err := os.Open(src)
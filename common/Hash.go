@@ -2,6 +2,8 @@ package common
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"dirstream"
 	"encoding/hex"
 	"io"
 	"os"
@@ -29,7 +31,24 @@ func Md5SumFile(filePath string) (string, error) {
 	return hex.EncodeToString(hash.Sum(nil)), nil
 }
 
-func ComputeDirectoryHashes(dirPath string, ignoredDirs []string) ([]FileHash, error) {
+// Sha256SumFile returns the hex-encoded SHA-256 digest of a file.
+func Sha256SumFile(filePath string) (string, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// ComputeDirectoryHashes hashes the files in the directory that the matcher does not exclude.
+// It uses the same matcher semantics as dirstream.BuildRelativeFileList, so the hashes cover exactly the streamed files.
+func ComputeDirectoryHashes(dirPath string, excludes *dirstream.IgnoreMatcher) ([]FileHash, error) {
 	var fileHashes []FileHash
 
 	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
@@ -37,22 +56,22 @@ func ComputeDirectoryHashes(dirPath string, ignoredDirs []string) ([]FileHash, e
 			return err
 		}
 
-		// If it's a directory, check if it should be ignored.
-		if info.IsDir() {
-			for _, ignored := range ignoredDirs {
-				if info.Name() == ignored {
-					// Skip the entire directory and its contents.
-					return filepath.SkipDir
-				}
+		relativePath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+
+		// If it's excluded, skip it, along with the directory's contents.
+		if relativePath != "." && excludes.Match(filepath.ToSlash(relativePath), info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
 			}
-			// Continue walking if the directory is not ignored.
 			return nil
 		}
 
-		// For files, compute the relative path.
-		relativePath, err := filepath.Rel(dirPath, path)
-		if err != nil {
-			return err
+		// Continue walking into directories.
+		if info.IsDir() {
+			return nil
 		}
 
 		// Open the file.
@@ -87,29 +106,129 @@ func ComputeDirectoryHashes(dirPath string, ignoredDirs []string) ([]FileHash, e
 	return fileHashes, nil
 }
 
-func VerifyDirectoryIntegrity(dirPath string, fileHashes []FileHash) ([]string, error) {
-	var mismatched []string
+// ComputeFileHashes hashes a list of files relative to rootPath.
+// If flatPaths is true, the hashes are recorded by base name, matching FilesToStream.
+func ComputeFileHashes(rootPath string, files []string, flatPaths bool) ([]FileHash, error) {
+	var fileHashes []FileHash
+
+	for _, file := range files {
+		hash, err := Md5SumFile(filepath.Join(rootPath, file))
+		if err != nil {
+			return nil, err
+		}
+
+		relativePath := file
+		if flatPaths {
+			relativePath = filepath.Base(file)
+		}
+
+		fileHashes = append(fileHashes, FileHash{
+			RelativePath: filepath.ToSlash(filepath.Clean(relativePath)),
+			Hash:         hash,
+		})
+	}
+
+	sort.Slice(fileHashes, func(i, j int) bool {
+		return fileHashes[i].RelativePath < fileHashes[j].RelativePath
+	})
+
+	return fileHashes, nil
+}
+
+// IntegrityReport describes how a directory differs from its expected file hashes.
+type IntegrityReport struct {
+	Modified []string // Files whose contents differ from the expected hash.
+	Missing  []string // Expected files that no longer exist.
+	Added    []string // Files that exist but were not expected.
+}
+
+// IsIntact reports whether no differences were found.
+func (r IntegrityReport) IsIntact() bool {
+	return len(r.Modified) == 0 && len(r.Missing) == 0 && len(r.Added) == 0
+}
+
+// Mismatched returns the expected files that are modified or missing, which can be restored from the installer.
+func (r IntegrityReport) Mismatched() []string {
+	mismatched := append(append([]string{}, r.Modified...), r.Missing...)
+	sort.Strings(mismatched)
+	return mismatched
+}
+
+// IntegrityOptions controls the checks performed by VerifyDirectoryIntegrity.
+type IntegrityOptions struct {
+	// DetectAdded reports files in the directory that are not in the expected hashes.
+	DetectAdded bool
+	// Ignore lists gitignore-style patterns for legitimate runtime files that are never reported as added.
+	Ignore []string
+}
+
+// VerifyDirectoryIntegrity compares the files in dirPath with the expected hashes.
+func VerifyDirectoryIntegrity(dirPath string, fileHashes []FileHash, options IntegrityOptions) (IntegrityReport, error) {
+	var report IntegrityReport
+
+	expected := make(map[string]bool, len(fileHashes))
 
 	for _, fh := range fileHashes {
+		expected[fh.RelativePath] = true
+
 		fullPath := filepath.Join(dirPath, fh.RelativePath)
 		if _, err := os.Stat(fullPath); os.IsNotExist(err) {
-			// File does not exist, add to mismatched
-			mismatched = append(mismatched, fh.RelativePath)
+			// File does not exist, add to missing
+			report.Missing = append(report.Missing, fh.RelativePath)
 			continue
 		}
 
 		currentHash, err := Md5SumFile(fullPath)
 		if err != nil {
-			return nil, err
+			return report, err
 		}
 
 		// Check if the current file's hash matches the expected hash
 		if currentHash != fh.Hash {
-			mismatched = append(mismatched, fh.RelativePath)
+			report.Modified = append(report.Modified, fh.RelativePath)
+		}
+	}
+
+	if !options.DetectAdded {
+		return report, nil
+	}
+
+	ignore := dirstream.NewIgnoreMatcher(options.Ignore)
+
+	err := filepath.Walk(dirPath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(dirPath, path)
+		if err != nil {
+			return err
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if relativePath == "." {
+			return nil
 		}
+
+		if ignore.Match(relativePath, info.IsDir()) {
+			if info.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if !info.IsDir() && !expected[relativePath] {
+			report.Added = append(report.Added, relativePath)
+		}
+		return nil
+	})
+	if err != nil {
+		return report, err
 	}
 
-	return mismatched, nil
+	sort.Strings(report.Added)
+
+	return report, nil
 }
 
 func HashReadSeeker(rs io.ReadSeeker) (string, error) {
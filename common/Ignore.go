@@ -0,0 +1,25 @@
+package common
+
+import (
+	"dirstream"
+	"path/filepath"
+)
+
+// IgnoreFilename is the optional file of gitignore-style exclusion patterns in a payload directory.
+const IgnoreFilename = ".exepyignore"
+
+// LoadPayloadMatcher builds the exclusion matcher for a payload directory.
+// The ignoredPathParts setting is applied first, followed by the patterns of the directory's
+// .exepyignore file, which can override it (e.g. with "!" negations). The ignore file itself is excluded.
+func LoadPayloadMatcher(dir string, ignoredPathParts []string) (*dirstream.IgnoreMatcher, error) {
+	filePatterns, err := dirstream.LoadIgnoreFile(filepath.Join(dir, IgnoreFilename))
+	if err != nil {
+		return nil, err
+	}
+
+	matcher := dirstream.NewIgnoreMatcher(ignoredPathParts)
+	matcher.AddPatterns([]string{"/" + IgnoreFilename})
+	matcher.AddPatterns(filePatterns)
+
+	return matcher, nil
+}
@@ -0,0 +1,119 @@
+package common
+
+import (
+	"dirstream"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestIgnoreMatcher(t *testing.T) {
+	tests := []struct {
+		name     string
+		patterns []string
+		path     string
+		isDir    bool
+		want     bool
+	}{
+		// unanchored patterns match a name at any depth
+		{name: "name at the root", patterns: []string{"*.log"}, path: "app.log", want: true},
+		{name: "name in a subdirectory", patterns: []string{"*.log"}, path: "logs/2024/app.log", want: true},
+		{name: "name not matching", patterns: []string{"*.log"}, path: "app.txt", want: false},
+		{name: "file in an ignored directory", patterns: []string{"__pycache__"}, path: "pkg/__pycache__/mod.pyc", want: true},
+
+		// a leading or inner slash anchors a pattern to the root
+		{name: "anchored at the root", patterns: []string{"/build"}, path: "build", isDir: true, want: true},
+		{name: "anchored not in a subdirectory", patterns: []string{"/build"}, path: "src/build", isDir: true, want: false},
+		{name: "inner slash anchors", patterns: []string{"docs/*.md"}, path: "docs/readme.md", want: true},
+		{name: "inner slash not in a subdirectory", patterns: []string{"docs/*.md"}, path: "src/docs/readme.md", want: false},
+		{name: "star does not cross directories", patterns: []string{"docs/*.md"}, path: "docs/api/readme.md", want: false},
+
+		// a trailing slash matches directories only
+		{name: "directory-only on a directory", patterns: []string{"cache/"}, path: "cache", isDir: true, want: true},
+		{name: "directory-only on a file", patterns: []string{"cache/"}, path: "cache", want: false},
+		{name: "directory-only on a file inside", patterns: []string{"cache/"}, path: "src/cache/data.bin", want: true},
+
+		// "**" matches any number of directories
+		{name: "leading ** at the root", patterns: []string{"**/fixtures"}, path: "fixtures", isDir: true, want: true},
+		{name: "leading ** in a subdirectory", patterns: []string{"**/fixtures"}, path: "a/b/fixtures", isDir: true, want: true},
+		{name: "inner ** with no directories", patterns: []string{"src/**/test_*.py"}, path: "src/test_app.py", want: true},
+		{name: "inner ** with directories", patterns: []string{"src/**/test_*.py"}, path: "src/a/b/test_app.py", want: true},
+		{name: "inner ** stays anchored", patterns: []string{"src/**/test_*.py"}, path: "lib/src/test_app.py", want: false},
+		{name: "trailing ** matches contents", patterns: []string{"vendor/**"}, path: "vendor/lib/mod.py", want: true},
+		{name: "trailing ** not the directory itself", patterns: []string{"vendor/**"}, path: "vendor", isDir: true, want: false},
+
+		// "!" negates, and the last matching pattern wins
+		{name: "negation re-includes", patterns: []string{"*.log", "!keep.log"}, path: "keep.log", want: false},
+		{name: "negation of another file", patterns: []string{"*.log", "!keep.log"}, path: "drop.log", want: true},
+		{name: "later pattern overrides negation", patterns: []string{"!keep.log", "*.log"}, path: "keep.log", want: true},
+		{name: "negation inside an ignored directory", patterns: []string{"build/", "!build/keep.txt"}, path: "build/keep.txt", want: true},
+
+		// comments, blank lines and escapes
+		{name: "comment", patterns: []string{"# notes.txt"}, path: "# notes.txt", want: false},
+		{name: "escaped hash", patterns: []string{`\#notes.txt`}, path: "#notes.txt", want: true},
+		{name: "escaped exclamation mark", patterns: []string{`\!important.txt`}, path: "!important.txt", want: true},
+		{name: "blank line", patterns: []string{"", "   "}, path: "file.txt", want: false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			matcher := dirstream.NewIgnoreMatcher(test.patterns)
+			if got := matcher.Match(test.path, test.isDir); got != test.want {
+				t.Errorf("patterns %q on %q (directory %v): got %v, want %v", test.patterns, test.path, test.isDir, got, test.want)
+			}
+		})
+	}
+}
+
+func TestIgnoreMatcherMatchPath(t *testing.T) {
+	matcher := dirstream.NewIgnoreMatcher([]string{"/assets/*"})
+
+	if !matcher.MatchPath("assets/logo.png", false) {
+		t.Error("expected a direct child to match")
+	}
+	if matcher.MatchPath("assets/icons/logo.png", false) {
+		t.Error("expected a file in a matching subdirectory not to match")
+	}
+	if !matcher.Match("assets/icons/logo.png", false) {
+		t.Error("expected Match to take the matching parent directory into account")
+	}
+}
+
+func TestNilIgnoreMatcherMatchesNothing(t *testing.T) {
+	var matcher *dirstream.IgnoreMatcher
+	if matcher.Match("anything", false) || matcher.MatchPath("anything", false) {
+		t.Error("a nil matcher matched a path")
+	}
+}
+
+func TestLoadPayloadMatcher(t *testing.T) {
+	dir := t.TempDir()
+	ignoreFile := "# local overrides\n!.idea/\n*.tmp\n"
+	if err := os.WriteFile(filepath.Join(dir, IgnoreFilename), []byte(ignoreFile), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	matcher, err := LoadPayloadMatcher(dir, []string{"__pycache__", ".idea"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		path  string
+		isDir bool
+		want  bool
+	}{
+		{path: IgnoreFilename, want: true},
+		{path: "sub/" + IgnoreFilename, want: false},
+		{path: "pkg/__pycache__", isDir: true, want: true},
+		{path: ".idea", isDir: true, want: false},
+		{path: "scratch.tmp", want: true},
+		{path: "main.py", want: false},
+	}
+
+	for _, test := range tests {
+		if got := matcher.Match(test.path, test.isDir); got != test.want {
+			t.Errorf("%q (directory %v): got %v, want %v", test.path, test.isDir, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const InstallLogFilename = "install.log"
+
+// InstallLog records every file the bootstrapper placed in an install root.
+// Paths are stored relative to the root with forward slashes, one per line.
+type InstallLog struct {
+	root  string
+	files map[string]struct{}
+}
+
+func NewInstallLog(root string) *InstallLog {
+	return &InstallLog{root: root, files: make(map[string]struct{})}
+}
+
+// LoadInstallLog reads the install log of the given install root.
+func LoadInstallLog(root string) (*InstallLog, error) {
+	file, err := os.Open(filepath.Join(root, InstallLogFilename))
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	log := NewInstallLog(root)
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+
+		// an edited or damaged log must not make uninstall delete files elsewhere
+		relativePath, ok := cleanLogPath(line)
+		if !ok {
+			fmt.Println("Warning: ignoring install log entry outside the install root:", line)
+			continue
+		}
+		log.files[relativePath] = struct{}{}
+	}
+
+	return log, scanner.Err()
+}
+
+// Record adds a file to the log. Absolute paths must lie inside the install root; other paths are ignored.
+func (l *InstallLog) Record(filePath string) {
+	relativePath := filePath
+	if filepath.IsAbs(filePath) {
+		rel, err := filepath.Rel(l.root, filePath)
+		if err != nil {
+			return
+		}
+		relativePath = rel
+	}
+
+	if cleaned, ok := cleanLogPath(relativePath); ok {
+		l.files[cleaned] = struct{}{}
+	}
+}
+
+// cleanLogPath returns a path relative to the install root in the form stored in the log.
+// ok is false for absolute paths and paths that leave the install root.
+func cleanLogPath(relativePath string) (string, bool) {
+	if filepath.IsAbs(relativePath) || filepath.VolumeName(relativePath) != "" {
+		return "", false
+	}
+
+	cleaned := path.Clean(strings.ReplaceAll(relativePath, `\`, "/"))
+	// a colon starts a drive letter or a Windows stream name, which filepath only recognizes on Windows
+	if path.IsAbs(cleaned) || strings.Contains(cleaned, ":") || cleaned == "." || cleaned == ".." || strings.HasPrefix(cleaned, "../") {
+		return "", false
+	}
+
+	return cleaned, true
+}
+
+// RecordHashes adds every file of a component extracted to dir.
+func (l *InstallLog) RecordHashes(dir string, fileHashes []FileHash) {
+	for _, fh := range fileHashes {
+		l.Record(filepath.Join(dir, filepath.FromSlash(fh.RelativePath)))
+	}
+}
+
+// RecordPipInstalls adds the files listed in the RECORD of every package installed in sitePackagesDir.
+func (l *InstallLog) RecordPipInstalls(sitePackagesDir string) error {
+	records, err := filepath.Glob(filepath.Join(sitePackagesDir, "*.dist-info", "RECORD"))
+	if err != nil {
+		return err
+	}
+
+	for _, record := range records {
+		entries, err := ReadPipRecord(record)
+		if err != nil {
+			return err
+		}
+
+		for _, entry := range entries {
+			l.Record(entry.FullPath(sitePackagesDir))
+		}
+		l.Record(record)
+	}
+
+	return nil
+}
+
+// RemoveMissing drops the recorded files that do not exist in the install root.
+func (l *InstallLog) RemoveMissing() {
+	for file := range l.files {
+		if !DoesPathExist(filepath.Join(l.root, filepath.FromSlash(file))) {
+			delete(l.files, file)
+		}
+	}
+}
+
+// Files returns the recorded paths in sorted order.
+func (l *InstallLog) Files() []string {
+	files := make([]string, 0, len(l.files))
+	for file := range l.files {
+		files = append(files, file)
+	}
+	sort.Strings(files)
+	return files
+}
+
+// Save writes the log into the install root.
+func (l *InstallLog) Save() error {
+	l.Record(InstallLogFilename)
+	return SaveContentsToFile(filepath.Join(l.root, InstallLogFilename), strings.Join(l.Files(), "\n")+"\n")
+}
@@ -0,0 +1,136 @@
+package common
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const InstallManifestFilename = "install_manifest.json"
+
+const installManifestVersion = 1
+
+// InstallDigests maps an attachment name to the hashes of the files it extracts.
+type InstallDigests map[string][]FileHash
+
+// Lookup returns the hash recorded for a file in a component, or an empty string if it is not listed.
+func (d InstallDigests) Lookup(component, relativePath string) string {
+	relativePath = filepath.ToSlash(filepath.Clean(relativePath))
+	for _, fh := range d[component] {
+		if fh.RelativePath == relativePath {
+			return fh.Hash
+		}
+	}
+	return ""
+}
+
+// InstallManifest records what the bootstrapper installed so later installers can upgrade in place.
+type InstallManifest struct {
+	ManifestVersion  int            `json:"manifestVersion"`
+	AppID            string         `json:"appId,omitempty"`
+	Version          string         `json:"version,omitempty"`
+	InstallerHash    string         `json:"installerHash"`
+	RequirementsHash string         `json:"requirementsHash"`
+	Components       InstallDigests `json:"components"`
+}
+
+// NewInstallManifest creates a manifest for the files extracted by the given installer.
+// The application identity and version are taken from settings, which may be nil when they are unknown.
+func NewInstallManifest(settings *PythonSetupSettings, installerHash, requirementsHash string, digests InstallDigests) *InstallManifest {
+	manifest := &InstallManifest{
+		ManifestVersion:  installManifestVersion,
+		InstallerHash:    installerHash,
+		RequirementsHash: requirementsHash,
+		Components:       digests,
+	}
+
+	if settings != nil {
+		manifest.AppID = settings.AppIdentifier()
+		if settings.Version != nil {
+			manifest.Version = *settings.Version
+		}
+	}
+
+	return manifest
+}
+
+// LoadInstallManifest reads the install manifest from disk.
+func LoadInstallManifest(filename string) (*InstallManifest, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var manifest InstallManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, err
+	}
+
+	if manifest.Components == nil {
+		manifest.Components = InstallDigests{}
+	}
+
+	return &manifest, nil
+}
+
+// Save writes the install manifest to disk.
+func (m *InstallManifest) Save(filename string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(filename, data, 0644)
+}
+
+// FileChanges lists the differences between two sets of file hashes.
+type FileChanges struct {
+	Changed []string // Files that were added or whose contents differ.
+	Removed []string // Files that are no longer present.
+}
+
+// DiffFileHashes compares installed hashes against incoming hashes.
+func DiffFileHashes(installed, incoming []FileHash) FileChanges {
+	var changes FileChanges
+
+	installedHashes := make(map[string]string, len(installed))
+	for _, fh := range installed {
+		installedHashes[fh.RelativePath] = fh.Hash
+	}
+
+	for _, fh := range incoming {
+		hash, ok := installedHashes[fh.RelativePath]
+		if !ok || hash != fh.Hash {
+			changes.Changed = append(changes.Changed, fh.RelativePath)
+		}
+		delete(installedHashes, fh.RelativePath)
+	}
+
+	for relativePath := range installedHashes {
+		changes.Removed = append(changes.Removed, relativePath)
+	}
+
+	sort.Strings(changes.Changed)
+	sort.Strings(changes.Removed)
+
+	return changes
+}
+
+// RemoveFileAndEmptyParents removes a file below root and any parent directories it leaves empty.
+func RemoveFileAndEmptyParents(root, relativePath string) error {
+	fullPath := filepath.Join(root, filepath.FromSlash(relativePath))
+
+	if err := os.Remove(fullPath); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+
+	cleanRoot := filepath.Clean(root)
+	for dir := filepath.Dir(fullPath); dir != cleanRoot && len(dir) > len(cleanRoot); dir = filepath.Dir(dir) {
+		// os.Remove refuses to delete non-empty directories, which ends the walk.
+		if err := os.Remove(dir); err != nil {
+			break
+		}
+	}
+
+	return nil
+}
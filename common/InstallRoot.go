@@ -0,0 +1,84 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+const defaultInstallFolderName = "Python Program"
+
+// ResolveInstallRoot determines the directory the bootstrapper installs into.
+// The override (usually the --install-dir flag) takes precedence over the installDir setting,
+// which in turn takes precedence over the per-user default location for the application.
+// Under the sideBySide version policy, the version is appended to the location, wherever it came from.
+func ResolveInstallRoot(override string, settings *PythonSetupSettings) (string, error) {
+	installRoot := override
+
+	if installRoot == "" && settings.InstallDir != nil {
+		installRoot = *settings.InstallDir
+	}
+
+	if installRoot == "" {
+		applicationName := ""
+		if settings.ApplicationName != nil {
+			applicationName = *settings.ApplicationName
+		}
+
+		defaultRoot, err := DefaultInstallRoot(applicationName)
+		if err != nil {
+			return "", err
+		}
+		installRoot = defaultRoot
+	}
+
+	// with the side-by-side policy, each version gets its own folder
+	if settings.VersionPolicy != nil && *settings.VersionPolicy == VersionPolicySideBySide &&
+		settings.Version != nil && *settings.Version != "" {
+		installRoot = filepath.Join(installRoot, sanitizeFolderName(*settings.Version))
+	}
+
+	installRoot = os.ExpandEnv(installRoot)
+
+	absRoot, err := filepath.Abs(installRoot)
+	if err != nil {
+		return "", fmt.Errorf("error resolving install directory %s: %w", installRoot, err)
+	}
+
+	return absRoot, nil
+}
+
+// DefaultInstallRoot returns the per-user install location for an application.
+// On Windows this is %LOCALAPPDATA%\Programs\<name>, elsewhere ~/.local/share/<name>.
+func DefaultInstallRoot(applicationName string) (string, error) {
+	folderName := sanitizeFolderName(applicationName)
+
+	if runtime.GOOS == "windows" {
+		localAppData := os.Getenv("LOCALAPPDATA")
+		if localAppData == "" {
+			configDir, err := os.UserConfigDir()
+			if err != nil {
+				return "", fmt.Errorf("error locating user application directory: %w", err)
+			}
+			localAppData = configDir
+		}
+		return filepath.Join(localAppData, "Programs", folderName), nil
+	}
+
+	homeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", fmt.Errorf("error locating user home directory: %w", err)
+	}
+	return filepath.Join(homeDir, ".local", "share", folderName), nil
+}
+
+// sanitizeFolderName strips characters that are not allowed in Windows folder names.
+func sanitizeFolderName(name string) string {
+	name = strings.Trim(sanitizeFileName(name), " .")
+	if name == "" {
+		return defaultInstallFolderName
+	}
+	return name
+}
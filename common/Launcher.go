@@ -0,0 +1,200 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+)
+
+// LauncherFilename is the attachment of an installer holding the launcher executable, without configuration.
+// The installer makes a launcher for each entry point from it.
+const LauncherFilename = "launcher"
+
+// LauncherConfigName is the attachment that turns the bootstrapper into a launcher.
+const LauncherConfigName = "launcher.json"
+
+// Kinds of launcher an installer places in the install root.
+const (
+	LauncherConsole  = "console"
+	LauncherWindowed = "windowed"
+	LauncherNone     = "none"
+)
+
+// LauncherModes lists the valid values of the launcher setting.
+var LauncherModes = []string{LauncherConsole, LauncherWindowed, LauncherNone}
+
+// Working directory policies of the application.
+const (
+	WorkingDirectoryInherit     = "inherit"
+	WorkingDirectoryInstallRoot = "installRoot"
+	WorkingDirectoryScriptDir   = "scriptDir"
+)
+
+// WorkingDirectoryPolicies lists the valid values of the workingDirectory setting.
+var WorkingDirectoryPolicies = []string{WorkingDirectoryInherit, WorkingDirectoryInstallRoot, WorkingDirectoryScriptDir}
+
+// LauncherConfig tells a launcher what to run. Paths are slash-separated and relative to the launcher's directory,
+// so an installation keeps working when it is moved.
+type LauncherConfig struct {
+	Title     string `json:"title"`
+	Python    string `json:"python"`
+	ScriptDir string `json:"scriptDir"`
+	// Script is the script to run. It is empty when Module names the module to run, or with Function, the function to call.
+	Script   string `json:"script,omitempty"`
+	Module   string `json:"module,omitempty"`
+	Function string `json:"function,omitempty"`
+	// Flags are interpreter options, e.g. -X utf8, placed before the target.
+	Flags []string          `json:"flags,omitempty"`
+	Args  []string          `json:"args,omitempty"`
+	Env   map[string]string `json:"env,omitempty"`
+	// WorkingDirectory is a working directory policy.
+	WorkingDirectory string `json:"workingDirectory,omitempty"`
+	Windowed         bool   `json:"windowed"`
+}
+
+// NewLauncherConfig returns the configuration of the launcher of an entry point, which is placed in the install root.
+func NewLauncherConfig(settings *PythonSetupSettings, name string, entryPoint EntryPoint) LauncherConfig {
+	mode := entryPoint.Launcher
+	if mode == "" {
+		mode = *settings.Launcher
+	}
+	windowed := mode == LauncherWindowed
+
+	// pythonw.exe runs without a console
+	interpreter := "python.exe"
+	if windowed {
+		interpreter = "pythonw.exe"
+	}
+
+	scriptDir := path.Clean(filepath.ToSlash(*settings.ScriptExtractDir))
+
+	config := LauncherConfig{
+		Title:            *settings.ApplicationName,
+		Python:           path.Join(filepath.ToSlash(*settings.PythonExtractDir), interpreter),
+		ScriptDir:        scriptDir,
+		Flags:            settings.PythonFlags,
+		Args:             entryPoint.Args,
+		Env:              mergeEnv(settings.Env, entryPoint.Env),
+		WorkingDirectory: *settings.WorkingDirectory,
+		Windowed:         windowed,
+	}
+
+	if len(settings.ResolvedEntryPoints()) > 1 {
+		config.Title += " (" + name + ")"
+	}
+
+	if entryPoint.Module != "" {
+		config.Module = entryPoint.Module
+	} else if module, function, ok := entryPoint.Function(); ok {
+		config.Module, config.Function = module, function
+	} else {
+		config.Script = path.Join(scriptDir, filepath.ToSlash(entryPoint.Target))
+	}
+
+	return config
+}
+
+// mergeEnv returns the variables of base with those of override added or replacing them.
+func mergeEnv(base, override map[string]string) map[string]string {
+	if len(base) == 0 && len(override) == 0 {
+		return nil
+	}
+
+	merged := make(map[string]string, len(base)+len(override))
+	for name, value := range base {
+		merged[name] = value
+	}
+	for name, value := range override {
+		merged[name] = value
+	}
+	return merged
+}
+
+// Resolve returns the absolute paths of the interpreter, the script and the scripts directory for a launcher in launcherDir.
+// script is empty when the launcher runs a module or calls a function.
+func (c LauncherConfig) Resolve(launcherDir string) (python string, script string, scriptDir string) {
+	resolve := func(p string) string {
+		if p == "" {
+			return ""
+		}
+		return filepath.Join(launcherDir, filepath.FromSlash(p))
+	}
+	return resolve(c.Python), resolve(c.Script), resolve(c.ScriptDir)
+}
+
+// ResolveWorkingDirectory returns the directory the application runs in for a launcher in launcherDir,
+// or "" to keep the working directory of the caller.
+func (c LauncherConfig) ResolveWorkingDirectory(launcherDir string) string {
+	switch c.WorkingDirectory {
+	case WorkingDirectoryInstallRoot:
+		return launcherDir
+	case WorkingDirectoryScriptDir:
+		_, _, scriptDir := c.Resolve(launcherDir)
+		return scriptDir
+	}
+	return ""
+}
+
+// PythonArguments returns the arguments Python is started with: the interpreter flags, the target and the arguments,
+// the configured ones first. Modules are run with -m and scripts by path, so both are executed by runpy exactly
+// as "python -m module" and "python script.py" would, with __name__ set to "__main__" and sys.path[0] set up for
+// relative and package imports.
+func (c LauncherConfig) PythonArguments(script string, args []string) []string {
+	arguments := append([]string{}, c.Flags...)
+
+	switch {
+	case c.Function != "":
+		// the module and function are passed as arguments, so they need no quoting inside the code
+		arguments = append(arguments, "-c", callFunctionCode, c.Module, c.Function)
+	case c.Module != "":
+		arguments = append(arguments, "-m", c.Module)
+	default:
+		arguments = append(arguments, script)
+	}
+
+	arguments = append(arguments, c.Args...)
+	return append(arguments, args...)
+}
+
+// callFunctionCode calls the function of a module:function entry point, which follow as the first two arguments,
+// and exits with its return value like a console script generated by pip.
+const callFunctionCode = `import importlib, sys
+module, function = sys.argv[1], sys.argv[2]
+sys.argv = [module] + sys.argv[3:]
+target = importlib.import_module(module)
+for name in function.split("."):
+    target = getattr(target, name)
+sys.exit(target())
+`
+
+// LauncherPath returns the location of the launcher of an entry point in the install root.
+func LauncherPath(installRoot string, name string) string {
+	return filepath.Join(installRoot, name+".exe")
+}
+
+func validateLauncher(launcher string) error {
+	if !slices.Contains(LauncherModes, launcher) {
+		return fmt.Errorf("launcher %q is not one of %s", launcher, strings.Join(LauncherModes, ", "))
+	}
+	return nil
+}
+
+func validateWorkingDirectory(policy string) error {
+	if !slices.Contains(WorkingDirectoryPolicies, policy) {
+		return fmt.Errorf("workingDirectory %q is not one of %s", policy, strings.Join(WorkingDirectoryPolicies, ", "))
+	}
+	return nil
+}
+
+// validatePythonFlags rejects flags that select what Python runs, since the target does that.
+func validatePythonFlags(flags []string) error {
+	for _, flag := range flags {
+		// -c and -m may carry their argument attached, as in -mpkg; long options such as --check-hash-based-pycs are fine
+		if len(flag) >= 2 && flag[0] == '-' && (flag[1] == 'c' || flag[1] == 'm') {
+			return fmt.Errorf("pythonFlags may not contain %q; use mainScript, mainModule or entryPoints to choose what runs", flag)
+		}
+	}
+	return nil
+}
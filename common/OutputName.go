@@ -0,0 +1,66 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// outputNamePlaceholder matches a {{key}} placeholder in an output name template.
+var outputNamePlaceholder = regexp.MustCompile(`\{\{\s*([A-Za-z]+)\s*\}\}`)
+
+// ExpandOutputName replaces each {{key}} placeholder in the template with the value of the setting of that name,
+// e.g. "{{applicationName}}-{{version}}-setup.exe". Characters that are not allowed in file names are removed
+// from the values, so they cannot introduce directories.
+func ExpandOutputName(template string, settings *PythonSetupSettings) (string, error) {
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return "", err
+	}
+
+	var values map[string]any
+	if err := json.Unmarshal(data, &values); err != nil {
+		return "", err
+	}
+
+	var unknown []string
+	expanded := outputNamePlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := outputNamePlaceholder.FindStringSubmatch(placeholder)[1]
+
+		switch value := values[key].(type) {
+		case string:
+			return sanitizeFileName(value)
+		case bool, float64:
+			return fmt.Sprint(value)
+		}
+
+		unknown = append(unknown, key)
+		return ""
+	})
+
+	if len(unknown) > 0 {
+		return "", fmt.Errorf("outputName %q refers to unknown or non-scalar settings: %s", template, strings.Join(unknown, ", "))
+	}
+
+	if strings.Contains(expanded, "{{") || strings.Contains(expanded, "}}") {
+		return "", fmt.Errorf("outputName %q has a malformed placeholder", template)
+	}
+
+	if filepath.Base(expanded) == "." || strings.HasSuffix(filepath.ToSlash(expanded), "/") {
+		return "", fmt.Errorf("outputName %q does not name a file", template)
+	}
+
+	return expanded, nil
+}
+
+// sanitizeFileName removes the characters that are not allowed in a Windows file name.
+func sanitizeFileName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(`<>:"/\|?*`, r) || r < 32 {
+			return -1
+		}
+		return r
+	}, name)
+}
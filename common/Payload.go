@@ -0,0 +1,228 @@
+package common
+
+import (
+	"dirstream"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+const payloadArrow = "->"
+
+// PayloadMapping maps source files on the build machine to a destination in the install root.
+//
+// The source is a file, a directory or a glob (with "**" for any depth) relative to the
+// configuration directory. The destination is relative to the install root; files keep their
+// path relative to the source directory or the glob's leading directories. A single file is
+// placed inside the destination if it ends with "/" or is ".", and renamed to it otherwise.
+//
+// In settings.json a mapping is either {"source": "assets/**", "destination": "data/"} or
+// the shorthand string "assets/** -> data/".
+type PayloadMapping struct {
+	Source      string `json:"source"`
+	Destination string `json:"destination"`
+}
+
+func (m *PayloadMapping) UnmarshalJSON(data []byte) error {
+	var shorthand string
+	if err := json.Unmarshal(data, &shorthand); err == nil {
+		source, destination, found := strings.Cut(shorthand, payloadArrow)
+		if !found {
+			return fmt.Errorf("invalid payload mapping %q: expected \"source %s destination\"", shorthand, payloadArrow)
+		}
+		m.Source = strings.TrimSpace(source)
+		m.Destination = strings.TrimSpace(destination)
+		return nil
+	}
+
+	type mapping PayloadMapping
+	return json.Unmarshal(data, (*mapping)(m))
+}
+
+func (m PayloadMapping) String() string {
+	return m.Source + " " + payloadArrow + " " + m.Destination
+}
+
+// ResolvePayload expands the mappings into the files to package.
+// Files matched by excludes are skipped. reserved maps archive paths already used by other
+// parts of the installer to a description of their owner; any overlap is reported as a collision,
+// as are two mappings that place different files at the same destination, and a file placed where
+// another mapping needs a directory.
+func ResolvePayload(baseDir string, mappings []PayloadMapping, excludes *dirstream.IgnoreMatcher, reserved map[string]string) ([]dirstream.FileEntry, error) {
+	owners := make(map[string]string, len(reserved))
+	for archivePath, owner := range reserved {
+		owners[archivePath] = owner
+	}
+
+	sources := make(map[string]string)
+
+	var entries []dirstream.FileEntry
+	var collisions []string
+
+	for _, mapping := range mappings {
+		mapped, err := resolvePayloadMapping(baseDir, mapping, excludes)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, entry := range mapped {
+			if owner, taken := owners[entry.ArchivePath]; taken {
+				// the same file mapped twice to the same place is harmless
+				if sources[entry.ArchivePath] != entry.SourcePath {
+					collisions = append(collisions, fmt.Sprintf("%s: %s and %s (%s)", entry.ArchivePath, owner, entry.SourcePath, mapping))
+				}
+				continue
+			}
+
+			owners[entry.ArchivePath] = fmt.Sprintf("%s (%s)", entry.SourcePath, mapping)
+			sources[entry.ArchivePath] = entry.SourcePath
+			entries = append(entries, entry)
+		}
+	}
+
+	// a path cannot be both a file and the directory of another file
+	for archivePath, owner := range owners {
+		for dir := path.Dir(archivePath); dir != "."; dir = path.Dir(dir) {
+			if dirOwner, taken := owners[dir]; taken {
+				collisions = append(collisions, fmt.Sprintf("%s: file of %s and directory of %s for %s", dir, dirOwner, owner, archivePath))
+			}
+		}
+	}
+
+	if len(collisions) > 0 {
+		sort.Strings(collisions)
+		return nil, fmt.Errorf("payload destinations collide:\n - %s", strings.Join(collisions, "\n - "))
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		return entries[i].ArchivePath < entries[j].ArchivePath
+	})
+
+	return entries, nil
+}
+
+// PayloadHashes hashes the payload files by their archive paths.
+func PayloadHashes(entries []dirstream.FileEntry) ([]FileHash, error) {
+	fileHashes := make([]FileHash, 0, len(entries))
+
+	for _, entry := range entries {
+		hash, err := Md5SumFile(entry.SourcePath)
+		if err != nil {
+			return nil, err
+		}
+		fileHashes = append(fileHashes, FileHash{RelativePath: entry.ArchivePath, Hash: hash})
+	}
+
+	return fileHashes, nil
+}
+
+func resolvePayloadMapping(baseDir string, mapping PayloadMapping, excludes *dirstream.IgnoreMatcher) ([]dirstream.FileEntry, error) {
+	if mapping.Source == "" {
+		return nil, fmt.Errorf("payload mapping %q has no source", mapping)
+	}
+
+	destination := filepath.ToSlash(mapping.Destination)
+	if path.IsAbs(destination) || filepath.IsAbs(mapping.Destination) {
+		return nil, fmt.Errorf("payload mapping %q: destination must be relative to the install root", mapping)
+	}
+
+	destinationIsDir := destination == "" || destination == "." || strings.HasSuffix(destination, "/")
+	destination = path.Clean(destination)
+	if destination == ".." || strings.HasPrefix(destination, "../") {
+		return nil, fmt.Errorf("payload mapping %q: destination is outside the install root", mapping)
+	}
+
+	source := filepath.ToSlash(mapping.Source)
+
+	if !strings.ContainsAny(source, "*?[") {
+		sourcePath := filepath.Join(baseDir, filepath.FromSlash(source))
+
+		info, err := os.Stat(sourcePath)
+		if err != nil {
+			return nil, fmt.Errorf("payload mapping %q: %w", mapping, err)
+		}
+
+		if !info.IsDir() {
+			archivePath := destination
+			if destinationIsDir {
+				archivePath = path.Join(destination, path.Base(source))
+			}
+			if archivePath == "." {
+				return nil, fmt.Errorf("payload mapping %q: destination is not a file path", mapping)
+			}
+			return []dirstream.FileEntry{{SourcePath: sourcePath, ArchivePath: archivePath}}, nil
+		}
+
+		return walkPayloadDir(sourcePath, destination, nil, excludes)
+	}
+
+	// split the glob into its leading directories and the pattern below them
+	segments := strings.Split(path.Clean(source), "/")
+	static := 0
+	for static < len(segments) && !strings.ContainsAny(segments[static], "*?[") {
+		static++
+	}
+
+	rootPath := filepath.Join(baseDir, filepath.FromSlash(strings.Join(segments[:static], "/")))
+	pattern := dirstream.NewIgnoreMatcher([]string{"/" + strings.Join(segments[static:], "/")})
+
+	entries, err := walkPayloadDir(rootPath, destination, pattern, excludes)
+	if err != nil {
+		return nil, fmt.Errorf("payload mapping %q: %w", mapping, err)
+	}
+
+	if len(entries) == 0 {
+		fmt.Printf("Warning: payload mapping %q matched no files\n", mapping)
+	}
+
+	return entries, nil
+}
+
+// walkPayloadDir lists the files below rootPath that match the include pattern (all files if nil)
+// and are not excluded, placing each under destination by its path relative to rootPath.
+func walkPayloadDir(rootPath string, destination string, include *dirstream.IgnoreMatcher, excludes *dirstream.IgnoreMatcher) ([]dirstream.FileEntry, error) {
+	var entries []dirstream.FileEntry
+
+	err := filepath.WalkDir(rootPath, func(fullPath string, d os.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		relativePath, err := filepath.Rel(rootPath, fullPath)
+		if err != nil {
+			return err
+		}
+		if relativePath == "." {
+			return nil
+		}
+		relativePath = filepath.ToSlash(relativePath)
+
+		if excludes.Match(relativePath, d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+
+		if d.IsDir() {
+			return nil
+		}
+
+		// the glob has to match the whole path, or "assets/*" would take in every subdirectory of assets
+		if include != nil && !include.MatchPath(relativePath, false) {
+			return nil
+		}
+
+		entries = append(entries, dirstream.FileEntry{
+			SourcePath:  fullPath,
+			ArchivePath: path.Join(destination, relativePath),
+		})
+		return nil
+	})
+
+	return entries, err
+}
@@ -0,0 +1,101 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// writePayloadTree creates the given slash-separated files below a temporary directory and returns it.
+func writePayloadTree(t *testing.T, files ...string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for _, file := range files {
+		fullPath := filepath.Join(dir, filepath.FromSlash(file))
+		if err := os.MkdirAll(filepath.Dir(fullPath), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(fullPath, []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestResolvePayloadGlobs(t *testing.T) {
+	dir := writePayloadTree(t, "assets/a.txt", "assets/b.png", "assets/nested/c.txt", "assets/nested/deeper/d.txt")
+
+	tests := []struct {
+		source string
+		want   []string
+	}{
+		{source: "assets/*", want: []string{"data/a.txt", "data/b.png"}},
+		{source: "assets/*.txt", want: []string{"data/a.txt"}},
+		{source: "assets/*/*.txt", want: []string{"data/nested/c.txt"}},
+		{source: "assets/**", want: []string{"data/a.txt", "data/b.png", "data/nested/c.txt", "data/nested/deeper/d.txt"}},
+		{source: "assets/**/*.txt", want: []string{"data/a.txt", "data/nested/c.txt", "data/nested/deeper/d.txt"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.source, func(t *testing.T) {
+			entries, err := ResolvePayload(dir, []PayloadMapping{{Source: test.source, Destination: "data/"}}, nil, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.ArchivePath)
+			}
+			if !reflect.DeepEqual(got, test.want) {
+				t.Errorf("expected %v, got %v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestResolvePayloadCollisions(t *testing.T) {
+	dir := writePayloadTree(t, "LICENSE", "other/LICENSE", "assets/a.txt")
+
+	tests := []struct {
+		name     string
+		mappings []PayloadMapping
+		reserved map[string]string
+		want     string
+	}{
+		{
+			name:     "two files at the same path",
+			mappings: []PayloadMapping{{Source: "LICENSE", Destination: "."}, {Source: "other/LICENSE", Destination: "."}},
+			want:     "LICENSE: ",
+		},
+		{
+			name:     "a file where a directory is needed",
+			mappings: []PayloadMapping{{Source: "LICENSE", Destination: "data"}, {Source: "assets/*", Destination: "data/"}},
+			want:     "data: file of ",
+		},
+		{
+			name:     "a directory where an installer file is",
+			mappings: []PayloadMapping{{Source: "assets/*", Destination: "run.bat/"}},
+			reserved: map[string]string{"run.bat": "installer"},
+			want:     "run.bat: file of installer",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := ResolvePayload(dir, test.mappings, nil, test.reserved)
+			if err == nil || !strings.Contains(err.Error(), test.want) {
+				t.Errorf("expected a collision containing %q, got %v", test.want, err)
+			}
+		})
+	}
+
+	// the same file mapped twice to the same place is not a collision
+	mappings := []PayloadMapping{{Source: "LICENSE", Destination: "."}, {Source: "LICENSE", Destination: "."}}
+	if _, err := ResolvePayload(dir, mappings, nil, nil); err != nil {
+		t.Errorf("expected no collision, got %v", err)
+	}
+}
@@ -0,0 +1,66 @@
+package common
+
+import (
+	"fmt"
+	"os"
+	"strings"
+)
+
+// PipInstallArguments returns the arguments of the embedded Python for "pip install" with args.
+// With pipIsolated, pip ignores the PIP_* variables and the per-user configuration of the machine it runs on and does
+// not use its cache, so an installation only depends on the installer and the pipOptions setting.
+func PipInstallArguments(settings *PythonSetupSettings, pythonExtractDir string, args ...string) []string {
+	arguments := []string{GetPipName(pythonExtractDir), "install"}
+
+	if *settings.PipIsolated {
+		arguments = append(arguments, "--isolated", "--no-cache-dir")
+	}
+
+	arguments = append(arguments, settings.PipOptions...)
+	return append(arguments, args...)
+}
+
+// PipEnvironment returns the variables set for pip commands of the installer.
+// --isolated still reads the global and site configuration files; pointing PIP_CONFIG_FILE at the null device makes
+// pip skip every configuration file, and PYTHONNOUSERSITE keeps packages in the user's site-packages from satisfying
+// requirements.
+func PipEnvironment(settings *PythonSetupSettings) map[string]string {
+	if !*settings.PipIsolated {
+		return nil
+	}
+
+	return map[string]string{
+		"PIP_CONFIG_FILE":  os.DevNull,
+		"PYTHONNOUSERSITE": "1",
+	}
+}
+
+// PipRunner returns a copy of runner that adds the variables of PipEnvironment to those it sets.
+func PipRunner(runner *CommandRunner, settings *PythonSetupSettings) *CommandRunner {
+	pipRunner := *runner
+	pipRunner.Env = make(map[string]string, len(runner.Env)+2)
+	for name, value := range runner.Env {
+		pipRunner.Env[name] = value
+	}
+	for name, value := range PipEnvironment(settings) {
+		pipRunner.Env[name] = value
+	}
+	return &pipRunner
+}
+
+// validatePipOptions rejects empty options and options that would undo the isolation of pip or install outside the embedded Python.
+func validatePipOptions(options []string) error {
+	for _, option := range options {
+		if option == "" {
+			return fmt.Errorf("pipOptions may not contain empty entries")
+		}
+		name, _, _ := strings.Cut(option, "=")
+		switch name {
+		case "--isolated", "--no-cache-dir":
+			return fmt.Errorf("pipOptions may not contain %s; use pipIsolated to control the isolation of pip", name)
+		case "--user", "--target", "--prefix", "--root":
+			return fmt.Errorf("pipOptions may not contain %s, which installs outside the embedded Python", name)
+		}
+	}
+	return nil
+}
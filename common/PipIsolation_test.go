@@ -0,0 +1,102 @@
+package common
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+// writeFakePipConfig writes a pip configuration with a bogus index and find-links directory to every location pip
+// reads user configuration from, and to PIP_CONFIG_FILE. It returns the find-links directory, which pip prints when
+// it uses the configuration.
+func writeFakePipConfig(t *testing.T) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	findLinks := filepath.Join(dir, "bogus-find-links")
+	config := "[global]\nindex-url = http://127.0.0.1:9/simple\nfind-links = " + findLinks + "\ntimeout = 1\nretries = 0\n"
+
+	for _, configPath := range []string{
+		filepath.Join(dir, "appdata", "pip", "pip.ini"),
+		filepath.Join(dir, "xdg", "pip", "pip.conf"),
+		filepath.Join(dir, "pip.ini"),
+	} {
+		if err := os.MkdirAll(filepath.Dir(configPath), os.ModePerm); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(configPath, []byte(config), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	t.Setenv("APPDATA", filepath.Join(dir, "appdata"))
+	t.Setenv("XDG_CONFIG_HOME", filepath.Join(dir, "xdg"))
+	t.Setenv("PIP_CONFIG_FILE", filepath.Join(dir, "pip.ini"))
+	t.Setenv("PIP_FIND_LINKS", findLinks)
+
+	return findLinks
+}
+
+// runIsolatedPip runs the host's pip the way the installer runs the embedded one, with the arguments and environment
+// of PipInstallArguments and PipRunner, and returns its standard output.
+func runIsolatedPip(t *testing.T, settings *PythonSetupSettings) string {
+	t.Helper()
+
+	arguments := PipInstallArguments(settings, "python", "--dry-run", "--no-index", "exepy-nonexistent-test-package")
+	if arguments[0] != GetPipName("python") || arguments[1] != "install" {
+		t.Fatalf("unexpected pip invocation %v", arguments)
+	}
+
+	// the host's pip module takes the place of the installer's pip.pyz
+	output, _ := PipRunner(&CommandRunner{}, settings).Output("python3", append([]string{"-m", "pip"}, arguments[1:]...)...)
+	return string(output)
+}
+
+func TestPipInstallArgumentsIsolation(t *testing.T) {
+	settings := DefaultSettings()
+	settings.PipOptions = []string{"--index-url", "https://pypi.example.com/simple"}
+
+	arguments := PipInstallArguments(settings, "python", "-r", "requirements.txt")
+	for _, expected := range []string{"--isolated", "--no-cache-dir", "--index-url"} {
+		if !slices.Contains(arguments, expected) {
+			t.Errorf("expected %s in %v", expected, arguments)
+		}
+	}
+
+	environment := PipEnvironment(settings)
+	if environment["PIP_CONFIG_FILE"] != os.DevNull || environment["PYTHONNOUSERSITE"] != "1" {
+		t.Errorf("unexpected pip environment %v", environment)
+	}
+
+	settings.PipIsolated = boolPtr(false)
+	if arguments := PipInstallArguments(settings, "python"); slices.Contains(arguments, "--isolated") {
+		t.Errorf("expected no --isolated without pipIsolated, got %v", arguments)
+	}
+	if environment := PipEnvironment(settings); len(environment) != 0 {
+		t.Errorf("expected no pip environment without pipIsolated, got %v", environment)
+	}
+}
+
+func TestPipIgnoresFakeConfiguration(t *testing.T) {
+	if err := exec.Command("python3", "-m", "pip", "--version").Run(); err != nil {
+		t.Skip("python3 with pip is not available:", err)
+	}
+
+	findLinks := writeFakePipConfig(t)
+
+	settings := DefaultSettings()
+
+	// without isolation pip picks up the fake configuration, which shows that the test can detect it
+	settings.PipIsolated = boolPtr(false)
+	if output := runIsolatedPip(t, settings); !strings.Contains(output, findLinks) {
+		t.Fatalf("expected pip to use the fake configuration without isolation, got:\n%s", output)
+	}
+
+	settings.PipIsolated = boolPtr(true)
+	if output := runIsolatedPip(t, settings); strings.Contains(output, findLinks) {
+		t.Errorf("isolated pip used the fake configuration:\n%s", output)
+	}
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// PipRecordEntry is a row of the RECORD file pip writes into each installed package's .dist-info directory.
+type PipRecordEntry struct {
+	Path string // Path relative to site-packages; may point outside it (e.g. ../../Scripts).
+	Hash string // Digest in "algorithm=urlsafe-base64" form; empty for files without a recorded hash.
+}
+
+// FullPath resolves the entry against the site-packages directory.
+func (e PipRecordEntry) FullPath(sitePackagesDir string) string {
+	return filepath.Join(sitePackagesDir, filepath.FromSlash(e.Path))
+}
+
+// ReadPipRecord parses a RECORD file, a CSV of "path,hash,size" rows.
+func ReadPipRecord(recordPath string) ([]PipRecordEntry, error) {
+	file, err := os.Open(recordPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	rows, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error parsing %s: %w", recordPath, err)
+	}
+
+	var entries []PipRecordEntry
+	for _, row := range rows {
+		if len(row) == 0 || row[0] == "" {
+			continue
+		}
+
+		entry := PipRecordEntry{Path: row[0]}
+		if len(row) > 1 {
+			entry.Hash = row[1]
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, nil
+}
+
+// VerifyInstalledPackages checks the files of every package in sitePackagesDir against the hashes in its RECORD.
+// It returns the names of the damaged distributions mapped to their missing or modified files.
+func VerifyInstalledPackages(sitePackagesDir string) (map[string][]string, error) {
+	records, err := filepath.Glob(filepath.Join(sitePackagesDir, "*.dist-info", "RECORD"))
+	if err != nil {
+		return nil, err
+	}
+
+	damaged := make(map[string][]string)
+
+	for _, record := range records {
+		entries, err := ReadPipRecord(record)
+		if err != nil {
+			return nil, err
+		}
+
+		distribution := distributionName(filepath.Base(filepath.Dir(record)))
+
+		for _, entry := range entries {
+			if entry.Hash == "" {
+				continue
+			}
+
+			matches, err := verifyRecordHash(entry.FullPath(sitePackagesDir), entry.Hash)
+			if err != nil {
+				return nil, err
+			}
+
+			if !matches {
+				damaged[distribution] = append(damaged[distribution], entry.Path)
+			}
+		}
+	}
+
+	return damaged, nil
+}
+
+// DamagedPackageNames returns the sorted distribution names of a VerifyInstalledPackages result.
+func DamagedPackageNames(damaged map[string][]string) []string {
+	names := make([]string, 0, len(damaged))
+	for name := range damaged {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// verifyRecordHash reports whether the file exists and matches a RECORD digest.
+// Only sha256 digests are checked; other algorithms are treated as matching.
+func verifyRecordHash(path string, recordHash string) (bool, error) {
+	algorithm, expected, found := strings.Cut(recordHash, "=")
+	if !found || algorithm != "sha256" {
+		return true, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return false, err
+	}
+
+	actual := base64.RawURLEncoding.EncodeToString(hash.Sum(nil))
+	return actual == strings.TrimRight(expected, "="), nil
+}
+
+// distributionName extracts the project name from a "name-version.dist-info" directory name.
+func distributionName(distInfoDir string) string {
+	name := strings.TrimSuffix(distInfoDir, ".dist-info")
+	if index := strings.LastIndex(name, "-"); index > 0 {
+		name = name[:index]
+	}
+	return name
+}
@@ -0,0 +1,150 @@
+package common
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"slices"
+	"strings"
+)
+
+// PythonArchitectures are the architectures python.org publishes embeddable distributions for.
+var PythonArchitectures = []string{"amd64", "win32", "arm64"}
+
+// pythonEmbedPattern matches the file name of a python.org embeddable distribution, e.g. python-3.11.7-embed-amd64.zip.
+var pythonEmbedPattern = regexp.MustCompile(`python-((\d+)\.(\d+)(?:\.\d+)?(?:[a-z]+\d+)?)(?:-embed-(amd64|win32|arm64))?`)
+
+// pythonVersionFormat is the form of the pythonVersion setting: a full release, optionally a pre-release.
+var pythonVersionFormat = regexp.MustCompile(`^(\d+)\.(\d+)\.\d+(?:(?:a|b|rc)\d+)?$`)
+
+// pythonReleasePattern matches the release part of a version, without any pre-release suffix.
+var pythonReleasePattern = regexp.MustCompile(`^\d+\.\d+\.\d+`)
+
+// PythonEmbedFilename returns the file name of the embeddable distribution of a Python version, e.g. python-3.12.4-embed-amd64.zip.
+func PythonEmbedFilename(version, arch string) string {
+	return "python-" + version + "-embed-" + arch + ".zip"
+}
+
+// PythonEmbedURL returns the python.org download URL of the embeddable distribution of a Python version.
+func PythonEmbedURL(version, arch string) string {
+	// pre-releases are published in the folder of the release they lead up to
+	folder := pythonReleasePattern.FindString(version)
+	if folder == "" {
+		folder = version
+	}
+	return "https://www.python.org/ftp/python/" + folder + "/" + PythonEmbedFilename(version, arch)
+}
+
+// PythonTag returns the major and minor version without a separator, e.g. "311", or "" if pythonVersion is malformed.
+func (s *PythonSetupSettings) PythonTag() string {
+	if s.PythonVersion == nil {
+		return ""
+	}
+
+	match := pythonVersionFormat.FindStringSubmatch(*s.PythonVersion)
+	if match == nil {
+		return ""
+	}
+
+	return match[1] + match[2]
+}
+
+// resolvePythonDistribution fills in pythonVersion and arch, and the names derived from them, that loaded leaves out.
+// Explicitly set names are kept, so a mirror or a custom build can still be configured by hand.
+func resolvePythonDistribution(loaded, defaults *PythonSetupSettings) {
+	if loaded.PythonVersion == nil {
+		loaded.PythonVersion = defaults.PythonVersion
+	}
+	if loaded.Arch == nil {
+		loaded.Arch = defaults.Arch
+	}
+
+	version, arch := *loaded.PythonVersion, *loaded.Arch
+	pthFile, interiorZip := loaded.DerivedDistributionNames()
+
+	if loaded.PythonDownloadURL == nil {
+		loaded.PythonDownloadURL = strPtr(PythonEmbedURL(version, arch))
+	}
+	if loaded.PythonDownloadZip == nil {
+		loaded.PythonDownloadZip = strPtr(PythonEmbedFilename(version, arch))
+	}
+	if loaded.PthFile == nil && pthFile != "" {
+		loaded.PthFile = strPtr(pthFile)
+	}
+	if loaded.PythonInteriorZip == nil && interiorZip != "" {
+		loaded.PythonInteriorZip = strPtr(interiorZip)
+	}
+}
+
+// DerivedDistributionNames returns the names of the ._pth file and the interior zip that pythonVersion implies,
+// e.g. python312._pth and python312.zip, or empty names if pythonVersion is malformed.
+func (s *PythonSetupSettings) DerivedDistributionNames() (pthFile string, interiorZip string) {
+	tag := s.PythonTag()
+	if tag == "" {
+		return "", ""
+	}
+	return "python" + tag + "._pth", "python" + tag + ".zip"
+}
+
+// validatePythonVersion checks the form of pythonVersion and arch.
+func validatePythonVersion(version, arch string) error {
+	if !pythonVersionFormat.MatchString(version) {
+		return fmt.Errorf("pythonVersion %q is not a Python release like 3.12.4", version)
+	}
+	if !slices.Contains(PythonArchitectures, arch) {
+		return fmt.Errorf("arch %q is not one of %s", arch, strings.Join(PythonArchitectures, ", "))
+	}
+	return nil
+}
+
+// validatePythonDistribution checks that a python.org download URL set by hand agrees with pythonVersion and arch.
+// pthFile and pythonInteriorZip are not compared with pythonVersion, since a custom build may name them differently;
+// DiscoverDistributionFile checks that they exist once the distribution is extracted.
+func (s *PythonSetupSettings) validatePythonDistribution() []error {
+	var problems []error
+
+	if match := pythonEmbedPattern.FindStringSubmatch(path.Base(*s.PythonDownloadURL)); match != nil {
+		if match[1] != *s.PythonVersion {
+			problems = append(problems, fmt.Errorf("pythonDownloadURL names Python %s but pythonVersion is %q", match[1], *s.PythonVersion))
+		}
+		if match[4] != "" && match[4] != *s.Arch {
+			problems = append(problems, fmt.Errorf("pythonDownloadURL names architecture %s but arch is %q", match[4], *s.Arch))
+		}
+	}
+
+	return problems
+}
+
+// DiscoverDistributionFile returns the name of the file in dir that plays the role of configured, e.g. the ._pth file.
+// configured is used when it exists. A name set by hand must exist; for a name derived from pythonVersion, dir is
+// scanned for the one file matching pattern instead, so distributions whose names do not follow the usual scheme
+// still work.
+func DiscoverDistributionFile(dir, configured, pattern string, derived bool) (string, error) {
+	if configured != "" && DoesPathExist(filepath.Join(dir, configured)) {
+		return configured, nil
+	}
+	if !derived {
+		return "", fmt.Errorf("the Python distribution does not contain %s", configured)
+	}
+
+	matches, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return "", err
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", fmt.Errorf("the Python distribution contains neither %s nor any file matching %s", configured, pattern)
+	case 1:
+		discovered := filepath.Base(matches[0])
+		fmt.Printf("%s not found in the Python distribution; using %s\n", configured, discovered)
+		return discovered, nil
+	}
+
+	names := make([]string, len(matches))
+	for i, match := range matches {
+		names[i] = filepath.Base(match)
+	}
+	return "", fmt.Errorf("the Python distribution does not contain %s, and several files match %s: %s", configured, pattern, strings.Join(names, ", "))
+}
@@ -0,0 +1,59 @@
+package common
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestDiscoverDistributionFile(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "python313t._pth"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name       string
+		configured string
+		derived    bool
+		want       string
+		wantErr    bool
+	}{
+		{name: "derived name present", configured: "python313t._pth", derived: true, want: "python313t._pth"},
+		{name: "derived name missing", configured: "python313._pth", derived: true, want: "python313t._pth"},
+		{name: "explicit name present", configured: "python313t._pth", want: "python313t._pth"},
+		{name: "explicit name missing", configured: "custom._pth", wantErr: true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got, err := DiscoverDistributionFile(dir, test.configured, "python*._pth", test.derived)
+			if test.wantErr {
+				if err == nil {
+					t.Errorf("expected an error, got %q", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("expected %q, got %q", test.want, got)
+			}
+		})
+	}
+}
+
+func TestValidatePythonDistributionKeepsExplicitNames(t *testing.T) {
+	settings := &PythonSetupSettings{PthFile: strPtr("custom._pth"), PythonInteriorZip: strPtr("custom-stdlib.zip")}
+	resolvePythonDistribution(settings, DefaultSettings())
+
+	if problems := settings.validatePythonDistribution(); len(problems) != 0 {
+		t.Errorf("expected explicit names to be accepted, got %v", problems)
+	}
+
+	settings.PythonDownloadURL = strPtr(PythonEmbedURL("3.12.4", *settings.Arch))
+	if problems := settings.validatePythonDistribution(); len(problems) != 1 {
+		t.Errorf("expected the download URL to be checked against pythonVersion, got %v", problems)
+	}
+}
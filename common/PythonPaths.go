@@ -0,0 +1,63 @@
+package common
+
+import (
+	"path"
+	"strings"
+)
+
+// SiteCustomizeFilename is the module Python imports at startup when the site module is enabled.
+const SiteCustomizeFilename = "sitecustomize.py"
+
+// GeneratePthFile returns the contents of the ._pth file that sets the module search path of the embedded Python.
+// Entries of a ._pth file are relative to the Python directory; the scripts directory and pythonPaths are relative
+// to the install root and are rewritten accordingly. In isolated mode the site module is not imported, so neither
+// site-packages .pth files nor sitecustomize.py are processed.
+func GeneratePthFile(settings *PythonSetupSettings) string {
+	lines := []string{
+		`.\` + *settings.PythonExtractDir,
+		`.\Scripts`,
+		`.`,
+		`.\Lib\site-packages`,
+	}
+
+	// climb from the Python directory to the install root
+	depth := len(strings.Split(path.Clean(strings.ReplaceAll(*settings.PythonExtractDir, `\`, "/")), "/"))
+	toRoot := strings.Repeat(`..\`, depth)
+
+	// the application's modules and packages are importable from the scripts directory whatever the working
+	// directory, since Python ignores PYTHONPATH when a ._pth file exists
+	searchPaths := append([]string{*settings.ScriptExtractDir}, settings.PythonPaths...)
+
+	added := make(map[string]bool, len(searchPaths))
+	for _, searchPath := range searchPaths {
+		cleaned := path.Clean(strings.ReplaceAll(searchPath, `\`, "/"))
+		if added[cleaned] {
+			continue
+		}
+		added[cleaned] = true
+
+		if cleaned == "." {
+			lines = append(lines, strings.TrimSuffix(toRoot, `\`))
+			continue
+		}
+		lines = append(lines, toRoot+strings.ReplaceAll(cleaned, "/", `\`))
+	}
+
+	if !*settings.PythonIsolated {
+		lines = append(lines, "import site")
+	}
+
+	return strings.Join(lines, "\n")
+}
+
+// GenerateSiteCustomize returns the contents of sitecustomize.py: the working directory is added to the module
+// search path, followed by the user-supplied fragment, if any.
+func GenerateSiteCustomize(fragment string) string {
+	contents := "import sys\nsys.path.append('.')"
+
+	if fragment != "" {
+		contents += "\n\n# from the siteCustomize setting\n" + strings.TrimRight(fragment, "\r\n") + "\n"
+	}
+
+	return contents
+}
@@ -0,0 +1,74 @@
+package common
+
+import (
+	"flag"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var updateGolden = flag.Bool("update", false, "rewrite the golden files in testdata")
+
+// checkGolden compares generated content with testdata/<name>.golden, or rewrites the file with -update.
+func checkGolden(t *testing.T, name string, actual string) {
+	t.Helper()
+
+	goldenPath := filepath.Join("testdata", name+".golden")
+	if *updateGolden {
+		if err := os.WriteFile(goldenPath, []byte(actual), 0644); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+
+	expected, err := os.ReadFile(goldenPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(expected) != actual {
+		t.Errorf("%s does not match the generated content:\n%s", goldenPath, actual)
+	}
+}
+
+func TestGeneratePthFile(t *testing.T) {
+	tests := []struct {
+		name   string
+		modify func(settings *PythonSetupSettings)
+	}{
+		{"pth_default", func(settings *PythonSetupSettings) {}},
+		{"pth_python_paths", func(settings *PythonSetupSettings) {
+			settings.PythonExtractDir = strPtr(`runtime\python`)
+			settings.PythonPaths = []string{".", "vendor/lib", `plugins\extra`, "scripts"}
+		}},
+		{"pth_scripts_in_root", func(settings *PythonSetupSettings) {
+			settings.ScriptExtractDir = strPtr(".")
+		}},
+		{"pth_isolated", func(settings *PythonSetupSettings) {
+			settings.PythonIsolated = boolPtr(true)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			settings := DefaultSettings()
+			test.modify(settings)
+			checkGolden(t, test.name, GeneratePthFile(settings))
+		})
+	}
+}
+
+func TestGenerateSiteCustomize(t *testing.T) {
+	tests := []struct {
+		name     string
+		fragment string
+	}{
+		{"sitecustomize_default", ""},
+		{"sitecustomize_fragment", "import os\nos.environ.setdefault('MYAPP_HOME', 'data')\n\n"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			checkGolden(t, test.name, GenerateSiteCustomize(test.fragment))
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package common
+
+import (
+	"bufio"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// requirementNamePattern matches the project name at the start of a requirement specifier.
+var requirementNamePattern = regexp.MustCompile(`^[A-Za-z0-9](?:[A-Za-z0-9._-]*[A-Za-z0-9])?`)
+
+// distributionSeparatorPattern matches the separators that are equivalent in project names.
+var distributionSeparatorPattern = regexp.MustCompile(`[-_.]+`)
+
+// NormalizeDistributionName returns the normalized form of a project name, so that e.g. "Foo_Bar" and "foo-bar" compare equal.
+func NormalizeDistributionName(name string) string {
+	return strings.ToLower(distributionSeparatorPattern.ReplaceAllString(name, "-"))
+}
+
+// ReadRequirementNames returns the project names required by a requirements file, in file order.
+// Options such as --find-links and nested -r files are skipped.
+func ReadRequirementNames(requirementsPath string) ([]string, error) {
+	file, err := os.Open(requirementsPath)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var names []string
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if index := strings.Index(line, "#"); index >= 0 {
+			line = line[:index]
+		}
+		line = strings.TrimSpace(line)
+
+		if line == "" || strings.HasPrefix(line, "-") {
+			continue
+		}
+
+		if name := requirementNamePattern.FindString(line); name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names, scanner.Err()
+}
+
+// InstalledDistributions returns the normalized names of the distributions installed in sitePackagesDir.
+func InstalledDistributions(sitePackagesDir string) (map[string]bool, error) {
+	distInfoDirs, err := filepath.Glob(filepath.Join(sitePackagesDir, "*.dist-info"))
+	if err != nil {
+		return nil, err
+	}
+
+	installed := make(map[string]bool, len(distInfoDirs))
+	for _, distInfoDir := range distInfoDirs {
+		installed[NormalizeDistributionName(distributionName(filepath.Base(distInfoDir)))] = true
+	}
+
+	return installed, nil
+}
+
+// UnresolvedRequirements returns the sorted requirements of a requirements file that are not installed in sitePackagesDir.
+func UnresolvedRequirements(requirementsPath string, sitePackagesDir string) ([]string, error) {
+	names, err := ReadRequirementNames(requirementsPath)
+	if err != nil {
+		return nil, err
+	}
+
+	installed, err := InstalledDistributions(sitePackagesDir)
+	if err != nil {
+		return nil, err
+	}
+
+	var unresolved []string
+	for _, name := range names {
+		if !installed[NormalizeDistributionName(name)] {
+			unresolved = append(unresolved, name)
+		}
+	}
+
+	sort.Strings(unresolved)
+	return unresolved, nil
+}
@@ -0,0 +1,161 @@
+package common
+
+import (
+	"crypto/rand"
+	"fmt"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// SBOMFilename is the attachment holding the CycloneDX software bill of materials of an installer.
+const SBOMFilename = "sbom.cdx.json"
+
+// CycloneDXBOM is the subset of the CycloneDX 1.5 JSON format that Exepy produces.
+type CycloneDXBOM struct {
+	BOMFormat    string               `json:"bomFormat"`
+	SpecVersion  string               `json:"specVersion"`
+	SerialNumber string               `json:"serialNumber"`
+	Version      int                  `json:"version"`
+	Metadata     CycloneDXMetadata    `json:"metadata"`
+	Components   []CycloneDXComponent `json:"components"`
+}
+
+type CycloneDXMetadata struct {
+	Timestamp string              `json:"timestamp"`
+	Tools     CycloneDXTools      `json:"tools"`
+	Component *CycloneDXComponent `json:"component,omitempty"`
+}
+
+type CycloneDXTools struct {
+	Components []CycloneDXComponent `json:"components"`
+}
+
+type CycloneDXComponent struct {
+	Type               string                       `json:"type"`
+	BOMRef             string                       `json:"bom-ref,omitempty"`
+	Name               string                       `json:"name"`
+	Version            string                       `json:"version,omitempty"`
+	Purl               string                       `json:"purl,omitempty"`
+	Hashes             []CycloneDXHash              `json:"hashes,omitempty"`
+	ExternalReferences []CycloneDXExternalReference `json:"externalReferences,omitempty"`
+}
+
+type CycloneDXHash struct {
+	Alg     string `json:"alg"`
+	Content string `json:"content"`
+}
+
+type CycloneDXExternalReference struct {
+	Type string `json:"type"`
+	URL  string `json:"url"`
+}
+
+// sbomFileComponents are the install digest components whose files are listed individually in the SBOM.
+// The Python runtime is listed as a single component instead of its thousands of files.
+var sbomFileComponents = []string{ScriptsFilename, CopyToRootFilename, PayloadFilename}
+
+// BuildSBOM describes the contents of an installer: the Python runtime, every packaged wheel with
+// its version, and the application files. Hashes are the MD5 digests the installer verifies files with.
+func BuildSBOM(settings *PythonSetupSettings, digests InstallDigests) CycloneDXBOM {
+	bom := CycloneDXBOM{
+		BOMFormat:    "CycloneDX",
+		SpecVersion:  "1.5",
+		SerialNumber: "urn:uuid:" + newUUID(),
+		Version:      1,
+		Metadata: CycloneDXMetadata{
+			Timestamp: time.Now().UTC().Format(time.RFC3339),
+			Tools: CycloneDXTools{Components: []CycloneDXComponent{
+				{Type: "application", Name: "exepy"},
+			}},
+			Component: &CycloneDXComponent{
+				Type:    "application",
+				BOMRef:  "application",
+				Name:    *settings.ApplicationName,
+				Version: *settings.Version,
+			},
+		},
+	}
+
+	runtime := CycloneDXComponent{
+		Type:    "platform",
+		BOMRef:  "python-runtime",
+		Name:    "python",
+		Version: *settings.PythonVersion,
+		ExternalReferences: []CycloneDXExternalReference{
+			{Type: "distribution", URL: *settings.PythonDownloadURL},
+		},
+	}
+	if runtime.Version != "" {
+		runtime.Purl = "pkg:generic/python@" + runtime.Version + "?download_url=" + *settings.PythonDownloadURL
+	}
+	bom.Components = append(bom.Components, runtime)
+
+	for _, wheel := range digests[WheelsFolderName] {
+		name, version, ok := ParseWheelFilename(path.Base(wheel.RelativePath))
+		if !ok {
+			continue
+		}
+
+		bom.Components = append(bom.Components, CycloneDXComponent{
+			Type:    "library",
+			BOMRef:  "wheel:" + wheel.RelativePath,
+			Name:    name,
+			Version: version,
+			Purl:    "pkg:pypi/" + normalizePackageName(name) + "@" + version,
+			Hashes:  []CycloneDXHash{{Alg: "MD5", Content: wheel.Hash}},
+		})
+	}
+
+	for _, component := range sbomFileComponents {
+		for _, file := range digests[component] {
+			bom.Components = append(bom.Components, CycloneDXComponent{
+				Type:   "file",
+				BOMRef: component + ":" + file.RelativePath,
+				Name:   file.RelativePath,
+				Hashes: []CycloneDXHash{{Alg: "MD5", Content: file.Hash}},
+			})
+		}
+	}
+
+	sort.SliceStable(bom.Components[1:], func(i, j int) bool {
+		a, b := bom.Components[1+i], bom.Components[1+j]
+		if a.Type != b.Type {
+			return a.Type > b.Type // libraries before files
+		}
+		return a.BOMRef < b.BOMRef
+	})
+
+	return bom
+}
+
+// ParseWheelFilename extracts the distribution name and version from a wheel file name,
+// e.g. "numpy-1.26.4-cp311-cp311-win_amd64.whl".
+func ParseWheelFilename(filename string) (name string, version string, ok bool) {
+	if !strings.HasSuffix(filename, ".whl") {
+		return "", "", false
+	}
+
+	// {distribution}-{version}(-{build tag})?-{python tag}-{abi tag}-{platform tag}.whl
+	parts := strings.Split(strings.TrimSuffix(filename, ".whl"), "-")
+	if len(parts) < 5 {
+		return "", "", false
+	}
+
+	return parts[0], parts[1], true
+}
+
+// normalizePackageName normalizes a distribution name as package URLs for PyPI require.
+func normalizePackageName(name string) string {
+	return strings.ToLower(strings.NewReplacer("_", "-", ".", "-").Replace(name))
+}
+
+// newUUID returns a random (version 4) UUID.
+func newUUID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	b[6] = b[6]&0x0f | 0x40
+	b[8] = b[8]&0x3f | 0x80
+	return fmt.Sprintf("%x-%x-%x-%x-%x", b[0:4], b[4:6], b[6:8], b[8:10], b[10:])
+}
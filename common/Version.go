@@ -0,0 +1,165 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// Version policies decide what an installer does when it finds another version of its application installed.
+const (
+	// VersionPolicyUpgrade replaces the installed version, asking for confirmation before a downgrade.
+	VersionPolicyUpgrade = "upgrade"
+	// VersionPolicyRefuseDowngrade replaces older and equal versions, and refuses to replace newer ones.
+	VersionPolicyRefuseDowngrade = "refuseDowngrade"
+	// VersionPolicySideBySide installs each version into its own folder below the install root.
+	VersionPolicySideBySide = "sideBySide"
+)
+
+// VersionPolicies lists the valid values of the versionPolicy setting.
+var VersionPolicies = []string{VersionPolicyUpgrade, VersionPolicyRefuseDowngrade, VersionPolicySideBySide}
+
+// AppIdentifier returns the identifier that tells this application apart from others in the same install directory.
+// It is the appId setting, or the application name if no appId is set.
+func (s *PythonSetupSettings) AppIdentifier() string {
+	if s.AppID != nil && *s.AppID != "" {
+		return *s.AppID
+	}
+	if s.ApplicationName != nil {
+		return *s.ApplicationName
+	}
+	return ""
+}
+
+// CompareVersions compares two dotted version strings, returning -1, 0 or 1.
+// Numeric parts are compared as numbers, missing parts count as zero, and a pre-release
+// suffix such as "-beta.1" or "rc1" sorts before the release it belongs to.
+func CompareVersions(a, b string) int {
+	releaseA, preA := splitVersion(a)
+	releaseB, preB := splitVersion(b)
+
+	for i := 0; i < max(len(releaseA), len(releaseB)); i++ {
+		var partA, partB int
+		if i < len(releaseA) {
+			partA = releaseA[i]
+		}
+		if i < len(releaseB) {
+			partB = releaseB[i]
+		}
+		if partA != partB {
+			if partA < partB {
+				return -1
+			}
+			return 1
+		}
+	}
+
+	switch {
+	case preA == preB:
+		return 0
+	case preA == "":
+		return 1
+	case preB == "":
+		return -1
+	}
+	return comparePreRelease(preA, preB)
+}
+
+// comparePreRelease compares two pre-release suffixes the way SemVer does: dot-separated identifiers are compared
+// in order, numeric identifiers as numbers and before alphanumeric ones, and a shorter suffix sorts first when it is
+// a prefix of the longer one. An identifier such as "rc10" compares its letters first and then its number, so it
+// sorts after "rc9".
+func comparePreRelease(a, b string) int {
+	identifiersA := strings.Split(a, ".")
+	identifiersB := strings.Split(b, ".")
+
+	for i := 0; i < min(len(identifiersA), len(identifiersB)); i++ {
+		if result := comparePreReleaseIdentifier(identifiersA[i], identifiersB[i]); result != 0 {
+			return result
+		}
+	}
+
+	return compareInts(len(identifiersA), len(identifiersB))
+}
+
+// comparePreReleaseIdentifier compares two identifiers of a pre-release suffix.
+func comparePreReleaseIdentifier(a, b string) int {
+	prefixA, numberA, hasNumberA := splitIdentifier(a)
+	prefixB, numberB, hasNumberB := splitIdentifier(b)
+
+	switch {
+	case prefixA != prefixB:
+		// a purely numeric identifier has an empty prefix and so sorts before alphanumeric ones
+		return strings.Compare(prefixA, prefixB)
+	case hasNumberA && hasNumberB:
+		return compareInts(numberA, numberB)
+	case hasNumberA != hasNumberB:
+		if hasNumberA {
+			return 1
+		}
+		return -1
+	}
+	return 0
+}
+
+// splitIdentifier splits a pre-release identifier into its leading text and a trailing number, if it has one.
+func splitIdentifier(identifier string) (string, int, bool) {
+	end := strings.LastIndexFunc(identifier, func(r rune) bool { return !unicode.IsDigit(r) }) + 1
+	number, err := strconv.Atoi(identifier[end:])
+	if err != nil {
+		return identifier, 0, false
+	}
+	return identifier[:end], number, true
+}
+
+// compareInts returns -1, 0 or 1 as a is less than, equal to or greater than b.
+func compareInts(a, b int) int {
+	switch {
+	case a < b:
+		return -1
+	case a > b:
+		return 1
+	}
+	return 0
+}
+
+// splitVersion splits a version into its numeric release parts and any pre-release suffix.
+func splitVersion(version string) ([]int, string) {
+	version = strings.TrimPrefix(strings.TrimSpace(version), "v")
+
+	var release []int
+	for version != "" {
+		end := strings.IndexFunc(version, func(r rune) bool { return !unicode.IsDigit(r) })
+		if end == -1 {
+			end = len(version)
+		}
+		if end == 0 {
+			break
+		}
+
+		part, err := strconv.Atoi(version[:end])
+		if err != nil {
+			break
+		}
+		release = append(release, part)
+
+		version = version[end:]
+		if !strings.HasPrefix(version, ".") || len(version) < 2 || !unicode.IsDigit(rune(version[1])) {
+			break
+		}
+		version = version[1:]
+	}
+
+	return release, strings.TrimLeft(version, "-.+_")
+}
+
+// validateVersionPolicy checks the versionPolicy setting.
+func validateVersionPolicy(policy string) error {
+	for _, valid := range VersionPolicies {
+		if policy == valid {
+			return nil
+		}
+	}
+	return fmt.Errorf("versionPolicy %q is not one of %s", policy, strings.Join(VersionPolicies, ", "))
+}
@@ -3,3 +3,8 @@ module lukasolson.net/common
 go 1.22.2
 
 toolchain go1.23.6
+
+require (
+	github.com/BurntSushi/toml v1.6.0
+	gopkg.in/yaml.v3 v3.0.1
+)
@@ -55,8 +55,8 @@ func writeChunks(w io.Writer, file *os.File, chunkSize int) error {
 }
 
 // readChunks reads file data in chunks from the reader,
-// verifies the combined CRC (over header and data), and writes the data to the file.
-func readChunks(r io.Reader, file *os.File, expectedSize uint64, chunkSize int) error {
+// verifies the combined CRC (over header and data), and writes the data to the writer.
+func readChunks(r io.Reader, file io.Writer, expectedSize uint64, chunkSize int) error {
 	var totalRead uint64
 	for totalRead < expectedSize {
 		// Read the full 16-byte header.
@@ -55,6 +55,13 @@ func (d *Decoder) recover(r io.Reader) error {
 	}
 }
 func (d *Decoder) Decode(r io.Reader) error {
+	return d.DecodeFiltered(r, nil)
+}
+
+// DecodeFiltered decodes only the entries for which include returns true.
+// include receives the entry's relative path with forward slashes; a nil include decodes everything.
+// The data of skipped files is still read and CRC-checked so the stream stays aligned.
+func (d *Decoder) DecodeFiltered(r io.Reader, include func(filePath string) bool) error {
 	bufferedReader := bufio.NewReader(r)
 
 	for {
@@ -92,6 +99,15 @@ func (d *Decoder) Decode(r io.Reader) error {
 			return fmt.Errorf("error reading header: %v", err)
 		}
 
+		if include != nil && !include(filepath.ToSlash(fh.FilePath)) {
+			if fh.FileType == fileTypeRegular {
+				if err := readChunks(bufferedReader, io.Discard, fh.FileSize, d.chunkSize); err != nil {
+					return fmt.Errorf("Decode: error skipping chunks for file %s: %v", fh.FilePath, err)
+				}
+			}
+			continue
+		}
+
 		fullPath, err := sanitizePath(d.destPath, fh.FilePath)
 		if err != nil {
 			return err
@@ -32,7 +32,29 @@ func NewEncoder(rootPath string, chunkSize int) *Encoder {
 	return &Encoder{rootPath: rootPath, chunkSize: chunkSize}
 }
 
+// FileEntry maps a file on disk to the path it is stored under in the stream.
+type FileEntry struct {
+	SourcePath  string // Path on disk, relative to the encoder's root unless absolute.
+	ArchivePath string // Relative path used when the stream is decoded.
+}
+
+// Encode streams the files in fileList, which are relative to the encoder's root.
+// If flatPaths is true, the files are stored without their directory structure.
 func (e *Encoder) Encode(fileList []string, flatPaths bool) (io.Reader, error) {
+	entries := make([]FileEntry, 0, len(fileList))
+	for _, relPath := range fileList {
+		archivePath := relPath
+		if flatPaths {
+			archivePath = filepath.Base(relPath)
+		}
+		entries = append(entries, FileEntry{SourcePath: relPath, ArchivePath: archivePath})
+	}
+
+	return e.EncodeEntries(entries)
+}
+
+// EncodeEntries streams each entry's source file under its archive path.
+func (e *Encoder) EncodeEntries(entries []FileEntry) (io.Reader, error) {
 	r, w := io.Pipe()
 	cw := &CountingWriter{w: w}
 	bufferedWriter := bufio.NewWriter(cw)
@@ -45,8 +67,12 @@ func (e *Encoder) Encode(fileList []string, flatPaths bool) (io.Reader, error) {
 			w.Close()
 		}()
 
-		for _, relPath := range fileList {
-			fullPath := filepath.Join(e.rootPath, relPath)
+		for _, entry := range entries {
+			fullPath := entry.SourcePath
+			if !filepath.IsAbs(fullPath) {
+				fullPath = filepath.Join(e.rootPath, fullPath)
+			}
+			relPath := entry.ArchivePath
 
 			info, err := os.Lstat(fullPath)
 			if err != nil {
@@ -54,10 +80,6 @@ func (e *Encoder) Encode(fileList []string, flatPaths bool) (io.Reader, error) {
 				return
 			}
 
-			if flatPaths {
-				relPath = filepath.Base(relPath)
-			}
-
 			var fh fileHeader
 			fh.Version = headerVersion
 			fh.FilePath = relPath
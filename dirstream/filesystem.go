@@ -61,7 +61,9 @@ func sanitizePath(rootPath, filePath string) (string, error) {
 	return absCleanPath, nil
 }
 
-func BuildRelativeFileList(rootPath string, excludes []string) ([]string, error) {
+// BuildRelativeFileList lists the files and directories below rootPath that the matcher does not exclude.
+// Excluded directories are skipped along with their contents.
+func BuildRelativeFileList(rootPath string, excludes *IgnoreMatcher) ([]string, error) {
 	var files []string
 
 	err := filepath.WalkDir(rootPath, func(path string, d os.DirEntry, err error) error {
@@ -81,17 +83,11 @@ func BuildRelativeFileList(rootPath string, excludes []string) ([]string, error)
 		}
 
 		// Check if the current directory or file should be excluded.
-		for _, exclude := range excludes {
-			// Use filepath.Base to get the name of the file or directory.
-			baseName := filepath.Base(path)
-
-			// Only skip if the base name exactly matches the exclude pattern.
-			if baseName == exclude {
-				if d.IsDir() {
-					return filepath.SkipDir
-				}
-				return nil
+		if excludes.Match(filepath.ToSlash(relPath), d.IsDir()) {
+			if d.IsDir() {
+				return filepath.SkipDir
 			}
+			return nil
 		}
 
 		files = append(files, relPath)
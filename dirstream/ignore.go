@@ -0,0 +1,171 @@
+package dirstream
+
+import (
+	"bufio"
+	"os"
+	"path"
+	"strings"
+)
+
+// IgnoreMatcher matches relative paths against gitignore-style patterns.
+//
+// Supported syntax: "#" comments, "!" negation, "*", "?" and "[...]" globs, "**" for any number of
+// directories, a leading or inner "/" to anchor a pattern to the root, and a trailing "/" to match
+// directories only. Patterns without a slash match a name at any depth. The last matching pattern wins.
+// A nil *IgnoreMatcher matches nothing.
+type IgnoreMatcher struct {
+	patterns []ignorePattern
+}
+
+type ignorePattern struct {
+	segments []string
+	negate   bool
+	dirOnly  bool
+}
+
+// NewIgnoreMatcher compiles the given patterns, in order of increasing precedence.
+func NewIgnoreMatcher(patterns []string) *IgnoreMatcher {
+	m := &IgnoreMatcher{}
+	m.AddPatterns(patterns)
+	return m
+}
+
+// AddPatterns appends patterns, which take precedence over the ones already added.
+func (m *IgnoreMatcher) AddPatterns(patterns []string) {
+	for _, line := range patterns {
+		if pattern, ok := parseIgnorePattern(line); ok {
+			m.patterns = append(m.patterns, pattern)
+		}
+	}
+}
+
+// LoadIgnoreFile reads the patterns of an ignore file. A missing file yields no patterns.
+func LoadIgnoreFile(filename string) ([]string, error) {
+	file, err := os.Open(filename)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		patterns = append(patterns, scanner.Text())
+	}
+
+	return patterns, scanner.Err()
+}
+
+// Match reports whether a slash-separated path relative to the root is ignored.
+// A path is also ignored when one of its parent directories is.
+func (m *IgnoreMatcher) Match(relativePath string, isDir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+
+	parts := strings.Split(strings.Trim(path.Clean(relativePath), "/"), "/")
+
+	for i := 1; i < len(parts); i++ {
+		if m.matchParts(parts[:i], true) {
+			return true
+		}
+	}
+
+	return m.matchParts(parts, isDir)
+}
+
+// MatchPath reports whether the patterns match the path itself. Unlike Match, a path inside a matching
+// directory is not matched, so "assets/*" selects the direct children of assets only.
+func (m *IgnoreMatcher) MatchPath(relativePath string, isDir bool) bool {
+	if m == nil || len(m.patterns) == 0 {
+		return false
+	}
+
+	return m.matchParts(strings.Split(strings.Trim(path.Clean(relativePath), "/"), "/"), isDir)
+}
+
+// matchParts applies the patterns to a single path, without considering its parents.
+func (m *IgnoreMatcher) matchParts(parts []string, isDir bool) bool {
+	ignored := false
+
+	for _, pattern := range m.patterns {
+		if pattern.dirOnly && !isDir {
+			continue
+		}
+		if matchSegments(pattern.segments, parts) {
+			ignored = !pattern.negate
+		}
+	}
+
+	return ignored
+}
+
+func parseIgnorePattern(line string) (ignorePattern, bool) {
+	var pattern ignorePattern
+
+	line = strings.TrimRight(line, " \t\r")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return pattern, false
+	}
+
+	if strings.HasPrefix(line, "!") {
+		pattern.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		// "\#" and "\!" escape a leading special character.
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		pattern.dirOnly = true
+		line = strings.TrimRight(line, "/")
+	}
+
+	// A slash at the start or in the middle anchors the pattern to the root.
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	if line == "" {
+		return pattern, false
+	}
+
+	pattern.segments = strings.Split(line, "/")
+	if !anchored && pattern.segments[0] != "**" {
+		pattern.segments = append([]string{"**"}, pattern.segments...)
+	}
+
+	return pattern, true
+}
+
+// matchSegments matches path components against pattern segments, where "**" matches
+// any number of components. A trailing "**" only matches the contents of a directory.
+func matchSegments(segments []string, parts []string) bool {
+	if len(segments) == 0 {
+		return len(parts) == 0
+	}
+
+	if segments[0] == "**" {
+		if len(segments) == 1 {
+			return len(parts) > 0
+		}
+		for i := 0; i <= len(parts); i++ {
+			if matchSegments(segments[1:], parts[i:]) {
+				return true
+			}
+		}
+		return false
+	}
+
+	if len(parts) == 0 {
+		return false
+	}
+
+	if matched, err := path.Match(segments[0], parts[0]); err != nil || !matched {
+		return false
+	}
+
+	return matchSegments(segments[1:], parts[1:])
+}
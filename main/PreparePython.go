@@ -8,64 +8,183 @@ import (
 	"path/filepath"
 )
 
-func PreparePython(settings common.PythonSetupSettings) (io.ReadSeeker, io.ReadSeeker, error) {
+// PreparePython builds the embedded Python distribution and the requirement wheels.
+// It returns the streams for both along with the digests of the files they contain.
+// The prepared runtime and wheels are reused from the build cache when their inputs are unchanged; a nil cache disables this.
+func PreparePython(settings common.PythonSetupSettings, cache *common.BuildCache) (io.ReadSeeker, io.ReadSeeker, common.InstallDigests, error) {
 
 	cleanDirectory(&settings)
 
 	defer cleanDirectory(&settings)
 
-	// CREATE THE EXTRACTION DIRECTORY
-	common.RemoveIfExists(*settings.PythonExtractDir)
-	if err := os.Mkdir(*settings.PythonExtractDir, os.ModePerm); err != nil {
-		fmt.Println("Error creating extraction directory:", err)
-		return nil, nil, err
+	siteCustomizeHash, err := common.HashFileContents(*settings.SiteCustomize)
+	if err != nil {
+		return nil, nil, nil, err
 	}
 
-	// DOWNLOAD PYTHON ZIP FILE
-	if err := common.DownloadFile(*settings.PythonDownloadURL, *settings.PythonDownloadZip); err != nil {
-		fmt.Println("Error downloading Python zip file:", err)
-		return nil, nil, err
-	}
+	// everything that determines the contents of the prepared runtime
+	runtimeKey := common.CacheKey(*settings.PythonDownloadURL, *settings.PythonSha256, *settings.PipDownloadURL, *settings.PipSha256,
+		*settings.PthFile, *settings.PythonInteriorZip, *settings.PythonExtractDir, common.GeneratePthFile(&settings), siteCustomizeHash)
 
-	// DOWNLOAD PIP FILE
-	if err := common.DownloadFile(*settings.PipDownloadURL, common.GetPipName(*settings.PythonExtractDir)); err != nil {
-		fmt.Println("Error downloading pip module:", err)
-		return nil, nil, err
-	}
+	if restoreFromCache(cache, common.CacheKindRuntime, runtimeKey, *settings.PythonExtractDir) {
+		fmt.Println("Using cached Python runtime")
+	} else {
+		if err := prepareRuntime(&settings); err != nil {
+			return nil, nil, nil, err
+		}
 
-	if err := createBasePythonInstallation(&settings, *settings.PythonDownloadZip); err != nil {
-		fmt.Println("Error creating base Python installation:", err)
-		return nil, nil, err
+		if err := cache.Store(common.CacheKindRuntime, runtimeKey, *settings.PythonExtractDir); err != nil {
+			fmt.Println("Warning: could not cache the Python runtime:", err)
+		}
 	}
 
-	common.RemoveIfExists(*settings.PythonDownloadZip)
-
-	pythonStream, err := common.DirToStream(*settings.PythonExtractDir, []string{})
+	pythonStream, err := common.DirToStream(*settings.PythonExtractDir, nil)
 
 	if err != nil {
 		fmt.Println("Error zipping Python directory:", err)
-		return nil, nil, err
+		return nil, nil, nil, err
+	}
+
+	pythonHashes, err := common.ComputeDirectoryHashes(*settings.PythonExtractDir, nil)
+	if err != nil {
+		fmt.Println("Error hashing Python directory:", err)
+		return nil, nil, nil, err
 	}
 
 	wheelsPath := filepath.Join(*settings.PythonExtractDir, common.WheelsFolderName)
 
-	os.Mkdir(wheelsPath, os.ModePerm)
+	requirementsHash, err := common.HashFileContents(*settings.InstallerRequirements)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	wheelsKey := common.CacheKey(runtimeKey, requirementsHash)
+
+	if restoreFromCache(cache, common.CacheKindWheelhouse, wheelsKey, wheelsPath) {
+		fmt.Println("Using cached wheels")
+	} else {
+		os.Mkdir(wheelsPath, os.ModePerm)
 
-	if *settings.InstallerRequirements != "" {
-		if common.DoesPathExist(*settings.InstallerRequirements) {
-			fmt.Println("Installer requirements file found:", *settings.InstallerRequirements)
-			if err := buildRequirementWheels(*settings.PythonExtractDir, *settings.InstallerRequirements, wheelsPath); err != nil {
-				return nil, nil, err
+		if *settings.InstallerRequirements != "" {
+			if common.DoesPathExist(*settings.InstallerRequirements) {
+				fmt.Println("Installer requirements file found:", *settings.InstallerRequirements)
+				if err := buildRequirementWheels(*settings.PythonExtractDir, *settings.InstallerRequirements, wheelsPath); err != nil {
+					return nil, nil, nil, err
+				}
+
+			} else {
+				fmt.Println("Installer requirements file not found but is specified in configuration:", settings.InstallerRequirements)
 			}
+		}
+
+		if err := cache.Store(common.CacheKindWheelhouse, wheelsKey, wheelsPath); err != nil {
+			fmt.Println("Warning: could not cache the wheels:", err)
+		}
+	}
 
+	if err := checkOfflineRequirements(&settings, wheelsPath); err != nil {
+		return nil, nil, nil, err
+	}
+
+	wheelsStream, _ := common.DirToStream(wheelsPath, nil)
+
+	wheelsHashes, err := common.ComputeDirectoryHashes(wheelsPath, nil)
+	if err != nil {
+		fmt.Println("Error hashing wheels directory:", err)
+		return nil, nil, nil, err
+	}
+
+	digests := common.InstallDigests{
+		common.PythonFilename:   pythonHashes,
+		common.WheelsFolderName: wheelsHashes,
+	}
+
+	return pythonStream, wheelsStream, digests, nil
+}
+
+// checkOfflineRequirements resolves the application's requirements against the wheels without installing anything,
+// so an installer that could not install them offline fails to build instead of failing on the user's machine.
+// It is skipped when onlineRequirements lets the installer fetch missing packages from the index.
+func checkOfflineRequirements(settings *common.PythonSetupSettings, wheelsPath string) error {
+	requirementsPath := filepath.Join(*settings.ScriptDir, *settings.RequirementsFile)
+	if *settings.OnlineRequirements || !common.DoesPathExist(requirementsPath) {
+		return nil
+	}
+
+	fmt.Println("Checking that the requirements can be installed offline...")
+
+	runner := common.PipRunner(&common.CommandRunner{}, settings)
+	pythonPath := filepath.Join(*settings.PythonExtractDir, "python.exe")
+
+	args := common.PipInstallArguments(settings, *settings.PythonExtractDir,
+		"--dry-run",
+		"--ignore-installed",
+		"--no-index",
+		"--find-links", filepath.Join(wheelsPath, "required"),
+		"--find-links", filepath.Join(wheelsPath, "setup"),
+		"--only-binary=:all:",
+		"-r", requirementsPath,
+	)
+
+	if err := runner.Run(pythonPath, args...); err != nil {
+		fmt.Println("Error: the wheels do not satisfy", requirementsPath)
+		if *settings.InstallerRequirements != "" {
+			fmt.Println("Add the missing packages to", *settings.InstallerRequirements, "or enable onlineRequirements.")
 		} else {
-			fmt.Println("Installer requirements file not found but is specified in configuration:", settings.InstallerRequirements)
+			fmt.Println("Set installerRequirements to a requirements file listing them, or enable onlineRequirements.")
 		}
+		return fmt.Errorf("requirements cannot be installed offline: %w", err)
 	}
 
-	wheelsStream, _ := common.DirToStream(wheelsPath, []string{})
+	return nil
+}
 
-	return pythonStream, wheelsStream, nil
+// restoreFromCache restores a cache entry into dest. A damaged entry is reported and treated as a miss.
+func restoreFromCache(cache *common.BuildCache, kind string, key string, dest string) bool {
+	restored, err := cache.Restore(kind, key, dest)
+	if err != nil {
+		fmt.Println("Warning:", err)
+		common.RemoveIfExists(dest)
+		return false
+	}
+	return restored
+}
+
+// prepareRuntime downloads Python and pip and sets up the base Python installation in the extraction directory.
+func prepareRuntime(settings *common.PythonSetupSettings) error {
+	// CREATE THE EXTRACTION DIRECTORY
+	common.RemoveIfExists(*settings.PythonExtractDir)
+	if err := os.Mkdir(*settings.PythonExtractDir, os.ModePerm); err != nil {
+		fmt.Println("Error creating extraction directory:", err)
+		return err
+	}
+
+	downloader, err := common.NewDownloader(common.DownloadOptions{Proxy: *settings.DownloadProxy, CAFile: *settings.DownloadCAFile})
+	if err != nil {
+		fmt.Println("Error configuring downloads:", err)
+		return err
+	}
+
+	// DOWNLOAD PYTHON ZIP FILE
+	if err := downloader.Download(*settings.PythonDownloadURL, *settings.PythonDownloadZip, *settings.PythonSha256); err != nil {
+		fmt.Println("Error downloading Python zip file:", err)
+		return err
+	}
+
+	// DOWNLOAD PIP FILE
+	if err := downloader.Download(*settings.PipDownloadURL, common.GetPipName(*settings.PythonExtractDir), *settings.PipSha256); err != nil {
+		fmt.Println("Error downloading pip module:", err)
+		return err
+	}
+
+	if err := createBasePythonInstallation(settings, *settings.PythonDownloadZip); err != nil {
+		fmt.Println("Error creating base Python installation:", err)
+		return err
+	}
+
+	common.RemoveIfExists(*settings.PythonDownloadZip)
+
+	return nil
 }
 
 func createBasePythonInstallation(settings *common.PythonSetupSettings, pythonZip string) error {
@@ -75,6 +194,11 @@ func createBasePythonInstallation(settings *common.PythonSetupSettings, pythonZi
 		return err
 	}
 
+	if err := discoverDistributionFiles(settings); err != nil {
+		fmt.Println("Error inspecting the Python distribution:", err)
+		return err
+	}
+
 	if err := extractInteriorPythonArchive(settings); err != nil {
 		return err
 	}
@@ -83,9 +207,11 @@ func createBasePythonInstallation(settings *common.PythonSetupSettings, pythonZi
 		return err
 	}
 
-	// write to sitecustomize.py file
-	if err := createSiteCustomFile(settings); err != nil {
-		return err
+	// sitecustomize.py is only run by the site module
+	if !*settings.PythonIsolated {
+		if err := createSiteCustomFile(settings); err != nil {
+			return err
+		}
 	}
 
 	// make empty DLLs folder
@@ -97,6 +223,26 @@ func createBasePythonInstallation(settings *common.PythonSetupSettings, pythonZi
 	return nil
 }
 
+// discoverDistributionFiles locates the ._pth file and the interior zip of the extracted distribution,
+// falling back to scanning for them when the names derived from pythonVersion are not present.
+func discoverDistributionFiles(settings *common.PythonSetupSettings) error {
+	derivedPthFile, derivedInteriorZip := settings.DerivedDistributionNames()
+
+	pthFile, err := common.DiscoverDistributionFile(*settings.PythonExtractDir, *settings.PthFile, "python*._pth", *settings.PthFile == derivedPthFile)
+	if err != nil {
+		return err
+	}
+
+	interiorZip, err := common.DiscoverDistributionFile(*settings.PythonExtractDir, *settings.PythonInteriorZip, "python*.zip", *settings.PythonInteriorZip == derivedInteriorZip)
+	if err != nil {
+		return err
+	}
+
+	settings.PthFile = &pthFile
+	settings.PythonInteriorZip = &interiorZip
+	return nil
+}
+
 func extractInteriorPythonArchive(settings *common.PythonSetupSettings) error {
 	// EXTRACT THE EMBEDDED PYTHON INTERIOR ZIP FILE
 
@@ -110,38 +256,29 @@ func extractInteriorPythonArchive(settings *common.PythonSetupSettings) error {
 }
 
 func createSiteCustomFile(settings *common.PythonSetupSettings) error {
-	sitecustomizeFile, err := os.Create(filepath.Join(*settings.PythonExtractDir, "sitecustomize.py"))
-
-	if err != nil {
-		return err
-	}
-
-	_, err = sitecustomizeFile.WriteString("import sys\nsys.path.append('.')")
-
-	if err != nil {
-		return err
+	fragment := ""
+	if *settings.SiteCustomize != "" {
+		contents, err := os.ReadFile(*settings.SiteCustomize)
+		if err != nil {
+			fmt.Println("Error reading siteCustomize file:", err)
+			return err
+		}
+		fragment = string(contents)
 	}
 
-	return nil
+	return os.WriteFile(filepath.Join(*settings.PythonExtractDir, common.SiteCustomizeFilename), []byte(common.GenerateSiteCustomize(fragment)), 0644)
 }
 
 func updatePTHFile(settings *common.PythonSetupSettings) error {
 	common.RemoveIfExists(filepath.Join(*settings.PythonExtractDir, *settings.PthFile))
 
 	// write to ._pth file
-	pthFile, err := os.Create(filepath.Join(*settings.PythonExtractDir, *settings.PthFile))
-	if err != nil {
-		fmt.Println("Error creating ._pth file:", err)
-		return nil
+	if err := os.WriteFile(filepath.Join(*settings.PythonExtractDir, *settings.PthFile), []byte(common.GeneratePthFile(settings)), 0644); err != nil {
+		fmt.Println("Error writing ._pth file:", err)
+		return err
 	}
 
-	// change python311 to pythonExtractDir
-	_, err = pthFile.WriteString(".\\" + *settings.PythonExtractDir + "\n.\\Scripts\n.\n.\\Lib\\site-packages\nimport site")
-	if err != nil {
-		fmt.Println("Error writing to ._pth file:", err)
-		return nil
-	}
-	return err
+	return nil
 }
 
 func buildRequirementWheels(extractDir, requirementsFile, wheelsPath string) error {
@@ -150,7 +287,7 @@ func buildRequirementWheels(extractDir, requirementsFile, wheelsPath string) err
 	pipPath := common.GetPipName(extractDir) // Get pip path once
 
 	// Install pip, setuptools, and wheel (if not already installed)
-	if err := common.RunCommand(pythonPath, []string{pipPath, "install", "--upgrade", "pip", "setuptools", "wheel"}); err != nil {
+	if err := common.RunCommand("", pythonPath, []string{pipPath, "install", "--upgrade", "pip", "setuptools", "wheel"}); err != nil {
 		fmt.Println("Error installing/upgrading pip, setuptools, wheel:", err)
 		return err
 	}
@@ -170,13 +307,13 @@ func buildRequirementWheels(extractDir, requirementsFile, wheelsPath string) err
 	}
 
 	// Build wheels for requirements
-	if err := common.RunCommand(pythonPath, []string{pipPath, "wheel", "-w", requiredWheelsDir, "-r", requirementsFile}); err != nil {
+	if err := common.RunCommand("", pythonPath, []string{pipPath, "wheel", "-w", requiredWheelsDir, "-r", requirementsFile}); err != nil {
 		fmt.Println("Error building requirement wheels:", err)
 		return err
 	}
 
 	// Build wheel for setuptools.
-	if err := common.RunCommand(pythonPath, []string{pipPath, "wheel", "-w", setupWheelsDir, "setuptools", "pip"}); err != nil {
+	if err := common.RunCommand("", pythonPath, []string{pipPath, "wheel", "-w", setupWheelsDir, "setuptools", "pip"}); err != nil {
 		fmt.Println("Error building setuptools/wheel wheels:", err)
 		return err
 	}
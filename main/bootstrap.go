@@ -8,10 +8,11 @@ import (
 	"io"
 	"lukasolson.net/common"
 	"os"
-	"path"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
+	"time"
 )
 
 const bootstrappedFileName = "bootstrapped"
@@ -22,12 +23,7 @@ var runScriptWindows string
 //go:embed run.sh
 var runScriptLinux string
 
-func bootstrap() error {
-
-	exit := ValidateExecutableHash()
-	if exit {
-		return fmt.Errorf("error validating executable hash")
-	}
+func bootstrap(opts bootstrapOptions) error {
 
 	attachments, err := ember.Open()
 	if err != nil {
@@ -35,37 +31,116 @@ func bootstrap() error {
 	}
 	defer attachments.Close()
 
+	settings, err := GetSettings(attachments)
+	if err != nil {
+		return err
+	}
+
+	common.AssumeYes = opts.yes
+
+	if opts.offline {
+		onlineRequirements := false
+		settings.OnlineRequirements = &onlineRequirements
+	}
+
+	installRoot, err := common.ResolveInstallRoot(opts.installDir, &settings)
+	if err != nil {
+		return err
+	}
+
+	bootstrappedPath := filepath.Join(installRoot, bootstrappedFileName)
+	manifestPath := filepath.Join(installRoot, common.InstallManifestFilename)
+
+	myHash, err := calculateSelfHash()
+	if err != nil {
+		return err
+	}
+
+	// the attachments are checked before anything about this installer is trusted or recorded
 	if !ValidateAttachmentHashes(attachments) {
 		return fmt.Errorf("error validating installer integrity")
 	}
 
-	settings, err := GetSettings(attachments)
+	versionChanged, err := checkInstalledVersion(manifestPath, settings)
 	if err != nil {
 		return err
 	}
 
+	exit := ValidateExecutableHash(bootstrappedPath, versionChanged)
+	if exit {
+		return fmt.Errorf("error validating executable hash")
+	}
+
 	applicationName := *settings.ApplicationName
 
 	if applicationName != "" {
-		println("Installing " + applicationName)
+		println("Installing " + applicationName + " " + *settings.Version)
+	}
+
+	fmt.Println("Install directory:", installRoot)
+
+	if err := os.MkdirAll(installRoot, os.ModePerm); err != nil {
+		fmt.Println("Error creating install directory:", err)
+		return err
 	}
 
-	confirmExtractionDir()
+	runner, closeRunner, err := newInstallRunner(installRoot)
+	if err != nil {
+		fmt.Println("Error creating install output log:", err)
+		return err
+	}
+	defer closeRunner()
+
+	confirmExtractionDir(installRoot, bootstrappedPath)
 
 	// check if the bootstrap has already been run
-	scriptExtractDir := *settings.ScriptExtractDir
+	scriptExtractDir := filepath.Join(installRoot, *settings.ScriptExtractDir)
+
+	pythonExtractDir := filepath.Join(installRoot, *settings.PythonExtractDir)
+
+	wheelsDir := filepath.Join(pythonExtractDir, common.WheelsFolderName)
 
-	if *settings.ScriptExtractDir == "" {
-		scriptExtractDir, _ = os.Getwd()
+	installDigests, err := GetInstallDigests(attachments)
+	if err != nil {
+		return err
+	}
+
+	// each embedded component and the directory it is extracted to
+	componentDirs := map[string]string{
+		common.PythonFilename:     pythonExtractDir,
+		common.ScriptsFilename:    scriptExtractDir,
+		common.WheelsFolderName:   wheelsDir,
+		common.CopyToRootFilename: installRoot,
+		common.PayloadFilename:    installRoot,
+	}
+
+	requirementsHash := installDigests.Lookup(common.ScriptsFilename, *settings.RequirementsFile)
+
+	installLog, err := startInstallLog(installRoot, componentDirs, installDigests, settings)
+	if err != nil {
+		fmt.Println("Error writing install log:", err)
+		return err
 	}
+	installLogFinished := false
+	defer func() {
+		if !installLogFinished {
+			if err := finishInstallLog(installLog, componentDirs); err != nil {
+				fmt.Println("Error writing install log:", err)
+			}
+		}
+	}()
 
-	pythonExtractDir := *settings.PythonExtractDir
+	scriptIntegrity := scriptIntegrityOptions(installRoot, scriptExtractDir, componentDirs, installDigests, settings)
 
 	integrityChecked := false
 
-	if _, err := os.Stat(bootstrappedFileName); os.IsNotExist(err) {
+	if _, err := os.Stat(bootstrappedPath); os.IsNotExist(err) {
 		// if the bootstrap has not been run, extract the Python and program files
 
+		if opts.repair {
+			fmt.Println("No existing installation to repair in", installRoot)
+		}
+
 		fmt.Println("Performing first time setup...")
 
 		fmt.Println("Reading embedded files...")
@@ -110,10 +185,6 @@ func bootstrap() error {
 
 		fmt.Println("Extracting Wheels...")
 
-		wheelsDir := path.Join(pythonExtractDir, common.WheelsFolderName)
-		requiredWheelsDir := filepath.Join(wheelsDir, "required")
-		setupWheelsDir := filepath.Join(wheelsDir, "setup")
-
 		err = common.StreamToDir(wheelsReader, wheelsDir)
 		if err != nil {
 			println("error extracting wheels zip file")
@@ -122,14 +193,25 @@ func bootstrap() error {
 
 		fmt.Println("Extracting files to copy to root...")
 
-		currentWorkingDir, err := os.Getwd()
-
-		err = common.StreamToDir(rootFilesReader, currentWorkingDir)
+		err = common.StreamToDir(rootFilesReader, installRoot)
 		if err != nil {
 			println("error extracting files to copy to root")
 			return err
 		}
 
+		fmt.Println("Extracting payload...")
+
+		mappedPayloadReader := attachments.Reader(common.PayloadFilename)
+		if mappedPayloadReader == nil {
+			return fmt.Errorf("error reading payload mappings. Ensure they are embedded in the binary")
+		}
+
+		err = common.StreamToDir(mappedPayloadReader, installRoot)
+		if err != nil {
+			println("error extracting payload mappings")
+			return err
+		}
+
 		fmt.Println("Extracted files successfully.")
 
 		// Validate the integrity of the extracted files
@@ -143,7 +225,7 @@ func bootstrap() error {
 			panic("Error reading data from reader: " + err.Error())
 		}
 
-		err, isIntegral := VerifyExtractionIntegrity(integrityData, scriptExtractDir)
+		_, isIntegral := VerifyExtractionIntegrity(integrityData, scriptExtractDir, scriptIntegrity)
 
 		if isIntegral != true {
 			fmt.Println("Error validating integrity of extracted files. Please try again or contact the distributor.")
@@ -155,66 +237,76 @@ func bootstrap() error {
 		integrityChecked = true
 
 		// install the required packages
-
-		fmt.Println("Installing required packages...")
-
-		pythonPath := filepath.Join(pythonExtractDir, "python.exe")
-
-		// Install all setup wheels first
-		if err := installWheels(pythonExtractDir, setupWheelsDir); err != nil {
-			fmt.Println("Error installing offline wheels.")
-		}
-
-		requirementsPath := path.Join(scriptExtractDir, *settings.RequirementsFile)
-
-		// if requirements.txt exists, install the offline requirements first
-		if _, err := os.Stat(requirementsPath); err == nil {
-			if err := common.RunCommand(pythonPath, []string{common.GetPipName(pythonExtractDir), "install", "--no-index", "--find-links",
-				path.Join(requiredWheelsDir) + "/", "--only-binary=:all:", "-r", requirementsPath}); err != nil {
-				fmt.Println("Error while installing requirements from disk... ")
-			}
+		if err := installRequirements(runner, pythonExtractDir, scriptExtractDir, settings); err != nil {
+			return err
 		}
 
-		if *settings.OnlineRequirements {
-			// Install the online requirements next
-			// Install missing requirements from PyPI *without* upgrading
-			if err := common.RunCommand(pythonPath, []string{
-				common.GetPipName(pythonExtractDir),
-				"install",
-				"--upgrade-strategy", "only-if-needed",
-				"-r", requirementsPath,
-			}); err != nil {
-				fmt.Println("Error installing missing requirements:", err)
-				return err
-			}
-		}
+		pythonPath := filepath.Join(pythonExtractDir, "python.exe")
 
 		// setup script path is relative to the extracted script directory
 		setupScriptName := *settings.SetupScript
 
 		// run the setup.py file if configured
 		if setupScriptName != "" {
-			setupScriptPath := path.Join(scriptExtractDir, setupScriptName)
-			if err := common.RunCommand(pythonPath, []string{setupScriptPath}); err != nil {
+			setupScriptPath := filepath.Join(scriptExtractDir, setupScriptName)
+			if err := runner.Run(pythonPath, setupScriptPath); err != nil {
 				fmt.Println("Error running "+setupScriptName+":", err)
 				return err
 			}
 		}
 
-		myHash, err := calculateSelfHash()
-
-		err = common.SaveContentsToFile(bootstrappedFileName, myHash)
+		err = common.SaveContentsToFile(bootstrappedPath, myHash)
 		if err != nil {
 			fmt.Println("Error saving hash to file:", err)
 		}
 
+		if err := common.NewInstallManifest(&settings, myHash, requirementsHash, installDigests).Save(manifestPath); err != nil {
+			fmt.Println("Error saving install manifest:", err)
+		}
+
+	} else {
+		manifest, err := loadInstallManifest(manifestPath)
+		if err != nil {
+			fmt.Println("Error reading install manifest:", err)
+			return err
+		}
+
+		if manifest.InstallerHash != myHash {
+			fmt.Println("Existing installation found. Applying update...")
+
+			requirementsChanged, err := upgradeInstallation(attachments, manifest, installDigests, componentDirs, requirementsHash)
+			if err != nil {
+				return err
+			}
+
+			if requirementsChanged {
+				fmt.Println("Requirements changed since the last installation.")
+				if err := installRequirements(runner, pythonExtractDir, scriptExtractDir, settings); err != nil {
+					return err
+				}
+			}
+
+			if err := common.NewInstallManifest(&settings, myHash, requirementsHash, installDigests).Save(manifestPath); err != nil {
+				fmt.Println("Error saving install manifest:", err)
+				return err
+			}
+
+			fmt.Println("Update applied successfully.")
+		}
+
+		if opts.repair {
+			if err := repairInstallation(attachments, runner, &settings, componentDirs, installDigests); err != nil {
+				return err
+			}
+			integrityChecked = true
+		}
 	}
 
 	// Copy the files to the root directory if they are listed in the settings and they exist
 	for _, file := range settings.FilesToCopyToRoot {
-		filePath := path.Join(scriptExtractDir, file)
+		filePath := filepath.Join(scriptExtractDir, file)
 		if common.DoesPathExist(filePath) {
-			err = common.CopyFile(filePath, file)
+			err = common.CopyFile(filePath, filepath.Join(installRoot, file))
 			if err != nil {
 				fmt.Println("Error copying file to root")
 				return err
@@ -234,84 +326,177 @@ func bootstrap() error {
 			panic("Error reading data from reader: " + err.Error())
 		}
 
-		err, isIntegral := VerifyExtractionIntegrity(integrityData, scriptExtractDir)
+		report, isIntegral := VerifyExtractionIntegrity(integrityData, scriptExtractDir, scriptIntegrity)
 
 		if isIntegral != true {
-			fmt.Println("Please rerun the installer to reinstall the environment.")
-			err := os.Remove(bootstrappedFileName)
-			if err != nil {
-				return err
+			if common.AskYesNo("Restore the modified files from the installer?", true) {
+				if err := repairInstallation(attachments, runner, &settings, componentDirs, installDigests); err != nil {
+					return err
+				}
+			} else {
+				fmt.Println("Please rerun the installer to reinstall the environment.")
+				err := os.Remove(bootstrappedPath)
+				if err != nil {
+					return err
+				}
+
+				// quit the program with an error code
+				return fmt.Errorf("file integrity check failed")
 			}
+		}
 
-			// quit the program with an error code
-			return fmt.Errorf("file integrity check failed")
+		if len(report.Added) > 0 {
+			if !common.AskYesNo("Unexpected files were found in the scripts directory. Continue anyway?", false) {
+				return fmt.Errorf("file integrity check failed: %d unexpected files", len(report.Added))
+			}
 		}
 	}
 
-	// if main script is not set, exit, as there is nothing to run
-	if *settings.MainScript == "" {
+	// launchers start the application without the quoting problems of the run script
+	launchers := make(map[string]string)
+	if len(settings.ResolvedEntryPoints()) > 0 {
+		launchers, err = installLaunchers(attachments, installRoot, settings)
+		if err != nil {
+			fmt.Println("Error installing launchers:", err)
+			return err
+		}
+
+		fmt.Println("Installed launchers:")
+		for _, name := range settings.EntryPointNames() {
+			fmt.Printf("  %-20s %s\n", name, launchers[name])
+		}
+	}
+
+	mainEntryPoint, hasMain := settings.MainEntryPoint()
+
+	// if neither a main script nor an entry point is set, exit, as there is nothing to run
+	if !hasMain && len(launchers) == 0 {
 		fmt.Println("Files installed. Exiting.")
 		return nil
-	} else {
+	}
+
+	runPath := ""
+	if hasMain {
+		// the run script reports errors and exit codes on the console, so it never uses pythonw.exe
+		mainEntryPoint.Launcher = common.LauncherConsole
+		config := common.NewLauncherConfig(&settings, *settings.RunScriptFileStem, mainEntryPoint)
 
-		runBatPath, err := createRunScript(pythonExtractDir, scriptExtractDir, path.Join(scriptExtractDir, *settings.MainScript), *settings.RunScriptFileStem)
+		runBatPath, err := createRunScript(installRoot, config, *settings.RunScriptFileStem)
 
 		if err != nil {
 			fmt.Println("Error creating run script")
 			return err
 		}
+		runPath = runBatPath
+	}
 
-		if *settings.RunAfterInstall {
-			fmt.Println("Running script...")
+	if name := settings.DefaultEntryPointName(); name != "" {
+		runPath = launchers[name]
+	}
 
-			if err := common.RunCommand(runBatPath, os.Args[1:]); err != nil {
-				fmt.Println("Error running script")
-				return err
-			}
+	// the log is complete before the application runs, which may not return
+	installLogFinished = true
+	if err := finishInstallLog(installLog, componentDirs); err != nil {
+		fmt.Println("Error writing install log:", err)
+	}
 
-			fmt.Println("Script completed.")
-		} else {
-			fmt.Println("Please run the following command in the command line to run the script:")
-			fmt.Println(runBatPath)
+	if *settings.RunAfterInstall && runPath != "" && !opts.noRun {
+		fmt.Println("Running script...")
+
+		if err := common.RunCommand(installRoot, runPath, opts.appArgs); err != nil {
+			fmt.Println("Error running script:", err)
+			return err
 		}
 
+		fmt.Println("Script completed.")
+	} else if len(launchers) == 0 {
+		fmt.Println("Please run the following command in the command line to run the script:")
+		fmt.Println(runPath)
 	}
 
 	return nil
 }
 
-func confirmExtractionDir() {
-	// if the current directory contains files other than this executable, ask the user to confirm the extraction directory
-	files, err := common.ListFilesInDir(".")
+func confirmExtractionDir(installRoot string, bootstrappedPath string) {
+	// an existing installation is expected to contain files
+	if common.DoesPathExist(bootstrappedPath) {
+		return
+	}
+
+	// if the install directory already contains files, ask the user to confirm the extraction directory
+	files, err := common.ListFilesInDir(installRoot)
 	if err != nil {
 		fmt.Println("Error listing files in directory:", err)
 	}
 
-	if len(files) > 1 {
-		fmt.Println("Warning: The current directory contains files other than this executable.")
+	if len(files) > 0 {
+		fmt.Println("Warning: The install directory is not empty:", installRoot)
 		fmt.Println("This may cause conflicts with the extracted files.")
 		fmt.Println("Please ensure the extraction directory is empty before continuing.")
 		common.PressButtonToContinue("Press enter to continue with installation...")
 	}
 }
 
-func createRunScript(pythonExtractDir string, scriptExtractDir string, mainScriptPath string, runScriptStem string) (string, error) {
-	// Create the run.bat
-	pythonExecutablePath := filepath.Join(pythonExtractDir, "python.exe")
+func createRunScript(installRoot string, config common.LauncherConfig, runScriptStem string) (string, error) {
+	python, script, _ := config.Resolve(installRoot)
 
 	var runscript string
+	var literal func(string) string
+	var quote func(string) string
+	var setVariable func(name, value string) string
+	var changeDirectory func(dir string) string
 
 	if runtime.GOOS == "windows" {
 		runscript = runScriptWindows
+		// percent signs would otherwise expand variables; double quotes cannot be escaped in a batch file
+		literal = func(value string) string { return strings.ReplaceAll(value, "%", "%%") }
+		quote = func(value string) string { return `"` + literal(value) + `"` }
+		setVariable = func(name, value string) string { return "set " + quote(name+"="+value) }
+		changeDirectory = func(dir string) string { return "cd /d " + quote(dir) }
 	} else {
 		runscript = runScriptLinux
+		quote = func(value string) string { return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'" }
+		literal = quote
+		setVariable = func(name, value string) string { return "export " + name + "=" + quote(value) }
+		changeDirectory = func(dir string) string { return "cd " + quote(dir) + " || exit 1" }
+	}
+
+	names := make([]string, 0, len(config.Env))
+	for name := range config.Env {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var environment []string
+	for _, name := range names {
+		environment = append(environment, setVariable(name, config.Env[name]))
+	}
+
+	workingDirectory := ""
+	if dir := config.ResolveWorkingDirectory(installRoot); dir != "" {
+		workingDirectory = changeDirectory(dir)
+	}
+
+	var arguments []string
+	for _, argument := range config.PythonArguments(script, nil) {
+		arguments = append(arguments, quote(argument))
 	}
 
 	// replace the placeholders in the runscript with the actual values
-	runscript = strings.ReplaceAll(runscript, "{{PYTHON_EXE}}", pythonExecutablePath)
-	runscript = strings.ReplaceAll(runscript, "{{MAIN_SCRIPT}}", mainScriptPath)
-	runscript = strings.ReplaceAll(runscript, "{{SCRIPTS_DIR}}", scriptExtractDir)
+	runscript = strings.ReplaceAll(runscript, "{{PYTHON_EXE}}", literal(python))
+	runscript = strings.ReplaceAll(runscript, "{{ENVIRONMENT}}", strings.Join(environment, "\n"))
+	runscript = strings.ReplaceAll(runscript, "{{CHANGE_DIRECTORY}}", workingDirectory)
+	runscript = strings.ReplaceAll(runscript, "{{PYTHON_ARGUMENTS}}", strings.Join(arguments, " "))
+
+	runBatPath := runScriptPath(installRoot, runScriptStem)
+
+	err := os.WriteFile(runBatPath, []byte(runscript), 0644)
+
+	return runBatPath, err
+}
 
+// runScriptPath returns the location of the generated run script in the install root.
+func runScriptPath(installRoot string, runScriptStem string) string {
 	if runScriptStem == "" {
 		runScriptStem = "run"
 	}
@@ -321,20 +506,70 @@ func createRunScript(pythonExtractDir string, scriptExtractDir string, mainScrip
 	} else {
 		runScriptStem += ".sh"
 	}
-	runBatPath, err := filepath.Abs(runScriptStem)
-	if err != nil {
-		return "", err
+	return filepath.Join(installRoot, runScriptStem)
+}
+
+// installRequirements installs the setup wheels and the application's requirements into the embedded Python.
+func installRequirements(runner *common.CommandRunner, pythonExtractDir string, scriptExtractDir string, settings common.PythonSetupSettings) error {
+	fmt.Println("Installing required packages...")
+
+	wheelsDir := filepath.Join(pythonExtractDir, common.WheelsFolderName)
+	requiredWheelsDir := filepath.Join(wheelsDir, "required")
+	setupWheelsDir := filepath.Join(wheelsDir, "setup")
+
+	// Install all setup wheels first
+	if err := installWheels(runner, &settings, pythonExtractDir, setupWheelsDir); err != nil {
+		fmt.Println("Error installing offline wheels:", err)
+		return err
 	}
 
-	err = os.WriteFile(runBatPath, []byte(runscript), 0644)
+	requirementsPath := filepath.Join(scriptExtractDir, *settings.RequirementsFile)
 
-	return runBatPath, err
+	if !common.DoesPathExist(requirementsPath) {
+		fmt.Println("No requirements file to install.")
+		return nil
+	}
+
+	// install the offline requirements first
+	if err := pipInstall(runner, &settings, pythonExtractDir, "--no-index", "--find-links",
+		requiredWheelsDir+string(filepath.Separator), "--only-binary=:all:", "-r", requirementsPath); err != nil {
+		if !*settings.OnlineRequirements {
+			return offlineInstallError(requirementsPath, pythonExtractDir, err)
+		}
+		fmt.Println("Not every requirement could be installed from disk; installing the rest from the package index.")
+	}
+
+	if *settings.OnlineRequirements {
+		// Install the online requirements next
+		// Install missing requirements from PyPI *without* upgrading
+		if err := pipInstall(runner, &settings, pythonExtractDir,
+			"--upgrade-strategy", "only-if-needed",
+			"-r", requirementsPath,
+		); err != nil {
+			fmt.Println("Error installing missing requirements:", err)
+			return err
+		}
+	}
+
+	return nil
 }
 
-func installWheels(extractDir, wheelDir string) error {
-	pythonPath := filepath.Join(extractDir, "python.exe")
-	pipPath := common.GetPipName(extractDir)
+// offlineInstallError describes a failed offline installation by the requirements that are still not installed.
+func offlineInstallError(requirementsPath string, pythonExtractDir string, err error) error {
+	unresolved, listErr := common.UnresolvedRequirements(requirementsPath, filepath.Join(pythonExtractDir, "Lib", "site-packages"))
+	if listErr != nil || len(unresolved) == 0 {
+		return fmt.Errorf("error installing requirements from disk: %w", err)
+	}
+
+	fmt.Println("The following requirements could not be installed from the installer's wheels:")
+	for _, name := range unresolved {
+		fmt.Println(" - " + name)
+	}
+
+	return fmt.Errorf("error installing requirements from disk, %d unresolved (%s): %w", len(unresolved), strings.Join(unresolved, ", "), err)
+}
 
+func installWheels(runner *common.CommandRunner, settings *common.PythonSetupSettings, extractDir, wheelDir string) error {
 	wheelFiles, err := filepath.Glob(filepath.Join(wheelDir, "*.whl"))
 	if err != nil {
 		return fmt.Errorf("error finding wheel files: %w", err)
@@ -347,7 +582,7 @@ func installWheels(extractDir, wheelDir string) error {
 
 	for _, wheelFile := range wheelFiles {
 		fmt.Println("Installing:", wheelFile) // Print the wheel file being installed.
-		if err := common.RunCommand(pythonPath, []string{pipPath, "install", wheelFile}); err != nil {
+		if err := pipInstall(runner, settings, extractDir, wheelFile); err != nil {
 			return fmt.Errorf("error installing wheel %s: %w", wheelFile, err)
 		}
 	}
@@ -355,7 +590,30 @@ func installWheels(extractDir, wheelDir string) error {
 	return nil
 }
 
-func VerifyExtractionIntegrity(integrityData []byte, scriptExtractDir string) (error, bool) {
+// newInstallRunner returns the runner for the commands of an installation, which run in the install root and
+// append their output to the install output log. The returned function closes the log.
+func newInstallRunner(installRoot string) (*common.CommandRunner, func(), error) {
+	logFile, err := os.OpenFile(filepath.Join(installRoot, common.InstallOutputLogFilename), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	fmt.Fprintf(logFile, "# %s\n", time.Now().Format(time.RFC3339))
+
+	runner := &common.CommandRunner{Dir: installRoot, Log: logFile}
+	return runner, func() { logFile.Close() }, nil
+}
+
+// pipInstall runs "pip install" with args in the embedded Python, isolated from the machine's pip configuration
+// unless the pipIsolated setting is off.
+func pipInstall(runner *common.CommandRunner, settings *common.PythonSetupSettings, pythonExtractDir string, args ...string) error {
+	pythonPath := filepath.Join(pythonExtractDir, "python.exe")
+	return common.PipRunner(runner, settings).Run(pythonPath, common.PipInstallArguments(settings, pythonExtractDir, args...)...)
+}
+
+// VerifyExtractionIntegrity checks the extracted scripts against the embedded hashes and describes any differences.
+// The returned bool is false if expected files are modified or missing; unexpected added files are only reported.
+func VerifyExtractionIntegrity(integrityData []byte, scriptExtractDir string, options common.IntegrityOptions) (common.IntegrityReport, bool) {
 
 	// these will be in the form of a json string, so we need to unmarshal them
 	var fileHashes []common.FileHash
@@ -364,34 +622,93 @@ func VerifyExtractionIntegrity(integrityData []byte, scriptExtractDir string) (e
 	err := json.Unmarshal(integrityData, &fileHashes)
 	if err != nil {
 		fmt.Println("Error unmarshalling JSON:", err)
-		return nil, false
+		return common.IntegrityReport{}, false
 	}
 
 	// get the hashes of the extracted files
-	tamperedFiles, err := common.VerifyDirectoryIntegrity(scriptExtractDir, fileHashes)
+	report, err := common.VerifyDirectoryIntegrity(scriptExtractDir, fileHashes, options)
 
 	if err != nil {
 		panic(err)
 	}
 
-	if len(tamperedFiles) > 0 {
+	if report.IsIntact() {
+		fmt.Println("Installation integrity validated successfully.")
+		return report, true
+	}
+
+	fmt.Println("Error validating integrity of extracted files.")
 
-		fmt.Println("Error validating integrity of extracted files.")
-		fmt.Println("Warning, the following files have been modified since installation:")
+	printIntegrityFiles("Warning, the following files have been modified since installation:", report.Modified)
+	printIntegrityFiles("Warning, the following files are missing:", report.Missing)
+	printIntegrityFiles("Warning, the following files were not installed by the installer and may be imported by the program:", report.Added)
 
-		for _, file := range tamperedFiles {
-			fmt.Println(" - " + file)
+	return report, len(report.Mismatched()) == 0
+}
+
+func printIntegrityFiles(message string, files []string) {
+	if len(files) == 0 {
+		return
+	}
+
+	fmt.Println(message)
+	for _, file := range files {
+		fmt.Println(" - " + file)
+	}
+}
+
+// scriptIntegrityOptions builds the options for checking the scripts directory.
+// Besides the configured ignore patterns, anything else the bootstrapper places in the
+// scripts directory (e.g. when scripts are extracted to the install root) is not reported as added.
+func scriptIntegrityOptions(installRoot string, scriptExtractDir string, componentDirs map[string]string, installDigests common.InstallDigests, settings common.PythonSetupSettings) common.IntegrityOptions {
+	ignore := settings.IntegrityIgnore
+	if ignore == nil {
+		ignore = common.DefaultIntegrityIgnore
+	}
+	ignore = append([]string{}, ignore...)
+
+	generated := []string{
+		componentDirs[common.PythonFilename],
+		filepath.Join(installRoot, bootstrappedFileName),
+		filepath.Join(installRoot, common.InstallManifestFilename),
+		filepath.Join(installRoot, common.InstallLogFilename),
+		filepath.Join(installRoot, common.InstallOutputLogFilename),
+		runScriptPath(installRoot, *settings.RunScriptFileStem),
+	}
+
+	for _, name := range settings.EntryPointNames() {
+		generated = append(generated, common.LauncherPath(installRoot, name))
+	}
+
+	for component, dir := range componentDirs {
+		if component == common.ScriptsFilename {
+			continue
+		}
+		for _, fh := range installDigests[component] {
+			generated = append(generated, filepath.Join(dir, filepath.FromSlash(fh.RelativePath)))
 		}
+	}
 
-		return nil, false
+	for _, file := range settings.FilesToCopyToRoot {
+		generated = append(generated, filepath.Join(installRoot, file))
+	}
 
-	} else {
-		fmt.Println("Installation integrity validated successfully.")
+	for _, path := range generated {
+		relativePath, err := filepath.Rel(scriptExtractDir, path)
+		if err != nil || relativePath == "." || strings.HasPrefix(relativePath, "..") {
+			continue
+		}
+		// a leading slash anchors the pattern to the scripts directory
+		ignore = append(ignore, "/"+filepath.ToSlash(relativePath))
 	}
-	return err, true
+
+	return common.IntegrityOptions{DetectAdded: true, Ignore: ignore}
 }
 
-func ValidateExecutableHash() (exit bool) {
+// ValidateExecutableHash compares this executable with the hash accepted by the previous installation and asks the
+// user to verify it when they differ. Another version of the installed application is expected to differ, but it
+// is only recorded after the user has accepted it, like an executable that changed without a new version.
+func ValidateExecutableHash(bootstrappedPath string, versionChanged bool) (exit bool) {
 	myHash, err := calculateSelfHash()
 
 	if err != nil {
@@ -399,16 +716,20 @@ func ValidateExecutableHash() (exit bool) {
 		return true
 	}
 
-	if common.DoesPathExist("bootstrapped") {
+	if common.DoesPathExist(bootstrappedPath) {
 		// read the hash from the file and compare it to the hash of the executable
-		fileHash, err := os.ReadFile("bootstrapped")
+		fileHash, err := os.ReadFile(bootstrappedPath)
 		if err != nil {
 			fmt.Println("Error reading hash file:", err)
 			return true
 		}
 
 		if strings.TrimSpace(string(fileHash)) != myHash {
-			fmt.Println("Error: Executable hash does not match previously accepted hash. File may have been tampered with.")
+			if versionChanged {
+				fmt.Println("This installer is a different version of the installed application.")
+			} else {
+				fmt.Println("Error: Executable hash does not match previously accepted hash. File may have been tampered with.")
+			}
 
 			fmt.Println("Expected:", string(fileHash))
 			fmt.Println("Actual:", myHash)
@@ -417,7 +738,7 @@ func ValidateExecutableHash() (exit bool) {
 
 			common.PressButtonToContinue("Press enter to accept the new hash and continue...")
 
-			err = common.SaveContentsToFile("bootstrapped", myHash)
+			err = common.SaveContentsToFile(bootstrappedPath, myHash)
 			if err != nil {
 				fmt.Println("Error saving hash to file:", err)
 				return true
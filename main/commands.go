@@ -0,0 +1,204 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"lukasolson.net/common"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+const creatorUsage = `Usage: exepy [command] [flags]
+
+Commands:
+  build                  build an installer from the configuration (the default)
+  init                   write a starter configuration file
+  config show            print the effective configuration
+  schema                 print the JSON Schema of the configuration file
+  inspect <installer>    list the attachments, sizes, hashes and settings of an installer
+  verify <installer>     check the integrity of an installer without installing it
+  extract <installer> <dir>
+                         unpack the attachments of an installer into a directory
+  cache prune            remove unused Python runtimes and wheels from the build cache
+  cache dir              print the location of the build cache
+
+Run "exepy <command> -h" for the flags of a command.`
+
+// isPrintSchemaFlag reports whether arg is --print-schema, an alias of the schema command.
+func isPrintSchemaFlag(arg string) bool {
+	return arg == "--print-schema" || arg == "-print-schema"
+}
+
+// runCreatorCommand runs a creator command. Without a command, or when the first argument is a flag, an installer is built.
+func runCreatorCommand(args []string) error {
+	command := "build"
+	if len(args) > 0 && (!strings.HasPrefix(args[0], "-") || isPrintSchemaFlag(args[0])) {
+		command, args = args[0], args[1:]
+	}
+
+	switch command {
+	case "build":
+		opts, err := parseBuildOptions(args)
+		if err != nil {
+			return err
+		}
+
+		PrintHeader() // Only print the header if we're in creator mode.
+
+		fmt.Println("No scripts have been embedded. Running in creator mode.")
+		return createInstaller(opts)
+	case "init":
+		return initConfig(args)
+	case "config":
+		if len(args) == 0 || args[0] != "show" {
+			return fmt.Errorf("unknown config command; expected \"config show\"")
+		}
+		return showConfig(args[1:])
+	case "schema", "--print-schema", "-print-schema":
+		schema, err := common.SettingsJSONSchema()
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(schema))
+		return nil
+	case "inspect":
+		return inspectInstaller(args)
+	case "verify":
+		return verifyInstaller(args)
+	case "extract":
+		return extractInstaller(args)
+	case "cache":
+		return runCacheCommand(args)
+	case "help":
+		fmt.Println(creatorUsage)
+		return nil
+	}
+
+	fmt.Println(creatorUsage)
+	return fmt.Errorf("unknown command %q", command)
+}
+
+// configFilename returns the configuration file selected by --config, or the one found in the working directory.
+func (opts configOptions) configFilename() (string, error) {
+	if opts.configFile != "" {
+		return opts.configFile, nil
+	}
+	return common.FindConfigFile(".")
+}
+
+// loadCreatorSettings loads the configuration with the EXEPY_* environment and --set overrides applied.
+// Paths in the configuration are relative to its directory, so the working directory is changed to it.
+func loadCreatorSettings(opts configOptions) (*common.PythonSetupSettings, error) {
+	filename, err := opts.configFilename()
+	if err != nil {
+		fmt.Println("Error finding settings file:", err)
+		return nil, err
+	}
+
+	setOverrides, err := common.ParseSetOverrides(opts.set)
+	if err != nil {
+		fmt.Println("Error parsing overrides:", err)
+		return nil, err
+	}
+
+	// later overrides win, so --set takes precedence over the environment
+	overrides := append(common.EnvOverrides(os.Environ()), setOverrides...)
+
+	settings, err := common.LoadSettings(filename, overrides)
+	if err != nil {
+		fmt.Println("Error loading settings file:", err)
+		return nil, err
+	}
+
+	if configDir := filepath.Dir(filename); configDir != "." {
+		if err := os.Chdir(configDir); err != nil {
+			return nil, err
+		}
+	}
+
+	return settings, nil
+}
+
+// runCacheCommand runs the cache subcommands that locate and prune the build cache.
+func runCacheCommand(args []string) error {
+	if len(args) == 0 {
+		return fmt.Errorf("missing cache command; expected \"cache prune\" or \"cache dir\"")
+	}
+
+	cache, err := common.OpenBuildCache()
+	if err != nil {
+		fmt.Println("Error opening build cache:", err)
+		return err
+	}
+
+	switch args[0] {
+	case "dir":
+		fmt.Println(cache.Dir())
+		return nil
+	case "prune":
+		flags := flag.NewFlagSet("cache prune", flag.ContinueOnError)
+		all := flags.Bool("all", false, "remove every entry")
+		olderThan := flags.Duration("older-than", 30*24*time.Hour, "remove entries not used for this long")
+
+		if err := flags.Parse(args[1:]); err != nil {
+			return err
+		}
+
+		maxAge := *olderThan
+		if *all {
+			maxAge = 0
+		} else if maxAge <= 0 {
+			return fmt.Errorf("--older-than must be positive; use --all to empty the cache")
+		}
+
+		removed, freed, err := cache.Prune(maxAge)
+		if err != nil {
+			fmt.Println("Error pruning build cache:", err)
+			return err
+		}
+
+		fmt.Printf("Removed %d cache entries, freeing %s\n", removed, formatSize(freed))
+		return nil
+	}
+
+	return fmt.Errorf("unknown cache command %q; expected \"cache prune\" or \"cache dir\"", args[0])
+}
+
+// initConfig writes a starter configuration file with the default settings.
+func initConfig(args []string) error {
+	flags := flag.NewFlagSet("init", flag.ContinueOnError)
+	force := flags.Bool("force", false, "overwrite an existing configuration file")
+	filename := flags.String("config", common.ConfigFileNames[0], "configuration file to write; the extension selects JSON, YAML or TOML")
+
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	if err := common.InitSettings(*filename, *force); err != nil {
+		fmt.Println("Error creating settings file:", err)
+		return err
+	}
+
+	fmt.Println("Created", *filename)
+	return nil
+}
+
+// showConfig prints the effective configuration: the settings file merged with the overrides and defaults.
+func showConfig(args []string) error {
+	opts, err := parseConfigOptions("config show", args)
+	if err != nil {
+		return err
+	}
+
+	settings, err := loadCreatorSettings(opts)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(os.Stdout)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(settings)
+}
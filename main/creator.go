@@ -2,6 +2,7 @@ package main
 
 import (
 	"bytes"
+	"dirstream"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -10,16 +11,50 @@ import (
 	"lukasolson.net/common"
 	"os"
 	"path"
+	"path/filepath"
+	"strings"
+	"time"
 	"windowsPE"
 )
 
-const settingsFileName = "exepy.json"
+func createInstaller(opts buildOptions) error {
+	startTime := time.Now()
 
-func createInstaller() error {
+	// resolve the output and stub paths before the working directory changes to the configuration directory
+	outputTemplate := ""
+	if opts.out != "" {
+		var err error
+		if outputTemplate, err = filepath.Abs(opts.out); err != nil {
+			return err
+		}
+	}
+
+	stubPath := ""
+	if opts.stub != "" {
+		var err error
+		if stubPath, err = filepath.Abs(opts.stub); err != nil {
+			return err
+		}
+	}
+
+	settings, err := loadCreatorSettings(opts.configOptions)
+	if err != nil {
+		return err
+	}
+
+	// without --out, the outputName setting applies, relative to the configuration directory
+	if outputTemplate == "" {
+		if outputTemplate, err = filepath.Abs(*settings.OutputName); err != nil {
+			return err
+		}
+	}
 
-	settings, err := common.LoadOrSaveDefault(settingsFileName)
+	outputPath, err := common.ExpandOutputName(outputTemplate, settings)
 	if err != nil {
-		fmt.Println("Error loading or saving settings file:", err.Error())
+		return err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(outputPath), os.ModePerm); err != nil {
 		return err
 	}
 
@@ -31,8 +66,8 @@ func createInstaller() error {
 		return errors.New("scripts directory does not exist")
 	}
 
-	// check if payload directory has the main file
-	if !common.DoesPathExist(pythonScriptPath) {
+	// check if payload directory has the main file, unless a module is run instead
+	if *settings.MainModule == "" && !common.DoesPathExist(pythonScriptPath) {
 		println("Main file does not exist: ", pythonScriptPath)
 		return errors.New("main file does not exist")
 	}
@@ -48,12 +83,33 @@ func createInstaller() error {
 
 	workingDirectory, _ := os.Getwd()
 
-	file, err := os.CreateTemp(workingDirectory, "installer-*.tmp")
+	file, err := os.CreateTemp(filepath.Dir(outputPath), "installer-*.tmp")
+	if err != nil {
+		return err
+	}
+
+	// the same matcher selects the streamed files and the hashed files, so the two always agree
+	payloadExcludes, err := common.LoadPayloadMatcher(*settings.ScriptDir, settings.IgnoredPathParts)
+	if err != nil {
+		return err
+	}
+
+	// resolve the payload mappings before the lengthy Python preparation so collisions are reported early
+	payloadEntries, err := resolvePayload(settings, workingDirectory, payloadExcludes)
 	if err != nil {
+		println("Error resolving payload:", err.Error())
 		return err
 	}
 
-	pythonFile, wheelsFile, err := PreparePython(*settings)
+	var cache *common.BuildCache
+	if !opts.noCache {
+		if cache, err = common.OpenBuildCache(); err != nil {
+			// the cache only saves time, so the build goes on without it
+			fmt.Println("Warning: build cache unavailable:", err)
+		}
+	}
+
+	pythonFile, wheelsFile, installDigests, err := PreparePython(*settings, cache)
 	if err != nil {
 		return err
 	}
@@ -79,9 +135,12 @@ func createInstaller() error {
 		return err
 	}
 
-	ignoredDirs := settings.IgnoredPathParts
+	CopyToRootHashes, err := common.ComputeFileHashes(currentWorkingDir, settings.FilesToCopyToRoot, true)
+	if err != nil {
+		return err
+	}
 
-	PayloadHashes, err := common.ComputeDirectoryHashes(*settings.ScriptDir, ignoredDirs)
+	PayloadHashes, err := common.ComputeDirectoryHashes(*settings.ScriptDir, payloadExcludes)
 	if err != nil {
 		return err
 	}
@@ -92,17 +151,44 @@ func createInstaller() error {
 		return err
 	}
 
-	PayloadFile, err := common.DirToStream(*settings.ScriptDir, ignoredDirs)
+	PayloadFile, err := common.DirToStream(*settings.ScriptDir, payloadExcludes)
+	if err != nil {
+		return err
+	}
+
+	MappedPayloadFile, err := common.EntriesToStream(currentWorkingDir, payloadEntries)
+	if err != nil {
+		return err
+	}
+
+	MappedPayloadHashes, err := common.PayloadHashes(payloadEntries)
+	if err != nil {
+		return err
+	}
+
+	installDigests[common.ScriptsFilename] = PayloadHashes
+	installDigests[common.CopyToRootFilename] = CopyToRootHashes
+	installDigests[common.PayloadFilename] = MappedPayloadHashes
+
+	installDigestsJson, err := json.Marshal(installDigests)
+	if err != nil {
+		return err
+	}
+
+	sbomJson, err := json.MarshalIndent(common.BuildSBOM(settings, installDigests), "", "  ")
 	if err != nil {
 		return err
 	}
 
-	SettingsFile, err := os.Open(settingsFileName)
-	defer SettingsFile.Close()
+	// embed the effective settings, since the configuration file may leave fields to their defaults
+	SettingsJson, err := json.Marshal(settings)
+	if err != nil {
+		return err
+	}
 
 	PayloadHashesReader := bytes.NewReader(PayloadHashesJson)
 
-	var SettingsFile2 io.ReadSeeker = SettingsFile
+	var SettingsFile2 io.ReadSeeker = bytes.NewReader(SettingsJson)
 	var PayloadIntegrity io.ReadSeeker = PayloadHashesReader
 
 	embedMap := make(map[string]io.ReadSeeker)
@@ -111,7 +197,19 @@ func createInstaller() error {
 	embedMap[common.ScriptIntegrityFilename] = PayloadIntegrity
 	embedMap[common.WheelsFolderName] = wheelsFile
 	embedMap[common.CopyToRootFilename] = CopyToRoot
+	embedMap[common.PayloadFilename] = MappedPayloadFile
 	embedMap[common.GetConfigEmbedName()] = SettingsFile2
+	embedMap[common.InstallDigestsFilename] = bytes.NewReader(installDigestsJson)
+	embedMap[common.SBOMFilename] = bytes.NewReader(sbomJson)
+
+	if len(settings.ResolvedEntryPoints()) > 0 {
+		launcherStub, err := buildLauncherStub(stubPath)
+		if err != nil {
+			fmt.Println("Error building launcher:", err)
+			return err
+		}
+		embedMap[common.LauncherFilename] = bytes.NewReader(launcherStub)
+	}
 
 	if common.ThemeMusicSupport {
 		themeWavPath := path.Join(currentWorkingDir, "theme.wav")
@@ -137,40 +235,86 @@ func createInstaller() error {
 
 	embedMap[common.HashmapName] = bytes.NewReader(hashBytes.Bytes())
 
-	if err := writeExecutable(file, embedMap); err != nil {
+	if err := writeExecutable(file, stubPath, embedMap); err != nil {
 		return err
 	}
 
-	outputExeHash, err := common.Md5SumFile(file.Name())
+	err = file.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	// move the file to the output path
+	err = os.Rename(file.Name(), outputPath)
 
 	if err != nil {
+		panic(err)
+	}
+
+	println("Embedded payload into", outputPath)
+
+	if err := writeBuildOutputs(outputPath, settings, embedMap, hashMap, installDigests, startTime); err != nil {
+		fmt.Println("Error writing build report:", err)
 		return err
 	}
 
-	println("Output executable hash: ", outputExeHash, " saved to hash.txt")
+	if opts.test {
+		if err := smokeTest(outputPath, settings, opts.testTimeout); err != nil {
+			fmt.Println("Smoke test failed:", err)
+			return err
+		}
+	}
 
-	// save the hash to a file
+	return nil
 
-	err = file.Close()
-	if err != nil {
-		panic(err)
+}
+
+// resolvePayload expands the payload mappings of the settings, relative to the configuration directory.
+// Destinations may not overlap the scripts, the files copied to root, the Python runtime or the files
+// the bootstrapper generates in the install root.
+func resolvePayload(settings *common.PythonSetupSettings, configDir string, scriptExcludes *dirstream.IgnoreMatcher) ([]dirstream.FileEntry, error) {
+	if len(settings.Payload) == 0 {
+		return nil, nil
 	}
 
-	// move the file to bootstrap.exe
-	err = os.Rename(file.Name(), "installer.exe")
+	reserved := make(map[string]string)
 
+	scriptFiles, err := dirstream.BuildRelativeFileList(*settings.ScriptDir, scriptExcludes)
 	if err != nil {
-		panic(err)
+		return nil, err
+	}
+	for _, file := range scriptFiles {
+		reserved[path.Join(filepath.ToSlash(*settings.ScriptExtractDir), filepath.ToSlash(file))] = "scripts directory " + *settings.ScriptDir
 	}
 
-	if err := common.SaveContentsToFile("hash.txt", outputExeHash); err != nil {
-		println("Error saving hash to file")
+	for _, file := range settings.FilesToCopyToRoot {
+		reserved[filepath.Base(file)] = "filesToCopyToRoot"
 	}
 
-	println("Embedded payload")
+	generatedFiles := []string{bootstrappedFileName, common.InstallManifestFilename, common.InstallLogFilename, common.InstallOutputLogFilename}
+	for _, name := range settings.EntryPointNames() {
+		generatedFiles = append(generatedFiles, filepath.Base(common.LauncherPath("", name)))
+	}
 
-	return nil
+	for _, file := range generatedFiles {
+		reserved[file] = "installer"
+	}
+
+	entries, err := common.ResolvePayload(configDir, settings.Payload, dirstream.NewIgnoreMatcher(settings.IgnoredPathParts), reserved)
+	if err != nil {
+		return nil, err
+	}
+
+	pythonDir := path.Clean(filepath.ToSlash(*settings.PythonExtractDir))
+	for _, entry := range entries {
+		if entry.ArchivePath == pythonDir || strings.HasPrefix(entry.ArchivePath, pythonDir+"/") {
+			return nil, fmt.Errorf("payload destination %s is inside the Python directory %s", entry.ArchivePath, pythonDir)
+		}
+	}
 
+	fmt.Println("Payload mappings resolved to", len(entries), "files")
+
+	return entries, nil
 }
 
 func calculateHashesFromMap(embedMap map[string]io.ReadSeeker) map[string]string {
@@ -193,12 +337,13 @@ func calculateHashesFromMap(embedMap map[string]io.ReadSeeker) map[string]string
 }
 
 // writeExecutable is a function that embeds attachments into a Python executable.
-// It takes two parameters:
+// It takes three parameters:
 // - writer: an io.Writer where the resulting executable will be written.
+// - stubPath: the bootstrapper executable to embed into; empty uses the current running program.
 // - attachments: a map where the key is the name of the attachment and the value is an io.ReadSeeker that reads the attachment's content.
-func writeExecutable(writer io.Writer, attachments map[string]io.ReadSeeker) error {
-	// Load the executable file of the current running program
-	executableBytes, err := loadSelf()
+func writeExecutable(writer io.Writer, stubPath string, attachments map[string]io.ReadSeeker) error {
+	// Load the bootstrapper executable
+	executableBytes, err := loadStub(stubPath)
 	// If an error occurred while loading the executable, return
 	if err != nil {
 		return err
@@ -230,18 +375,22 @@ func writeExecutable(writer io.Writer, attachments map[string]io.ReadSeeker) err
 	return nil
 }
 
-// loadSelf is a function that retrieves the executable file of the current running program.
+// loadStub is a function that retrieves the bootstrapper executable at stubPath, or the executable file of
+// the current running program if stubPath is empty.
 // It returns the file content as a byte slice and an error if any occurred during the process.
-func loadSelf() ([]byte, error) {
-	// Get the path of the executable file
-	selfPath, err := os.Executable()
-	// If an error occurred while getting the path, return the error
-	if err != nil {
-		return nil, err
+func loadStub(stubPath string) ([]byte, error) {
+	if stubPath == "" {
+		// Get the path of the executable file
+		selfPath, err := os.Executable()
+		// If an error occurred while getting the path, return the error
+		if err != nil {
+			return nil, err
+		}
+		stubPath = selfPath
 	}
 
 	// Open the executable file
-	file, err := os.Open(selfPath)
+	file, err := os.Open(stubPath)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,128 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// bootstrapOptions holds the command line options accepted by an installer.
+type bootstrapOptions struct {
+	installDir string
+	uninstall  bool
+	repair     bool
+	dryRun     bool
+	// yes answers yes to every question, so the installer can run unattended.
+	yes bool
+	// noRun skips running the application after installation, even with runAfterInstall.
+	noRun bool
+	// offline installs the requirements from the embedded wheels only, whatever the onlineRequirements setting.
+	offline bool
+	// appArgs are forwarded to the application when it is run after installation.
+	appArgs []string
+}
+
+// parseBootstrapOptions parses the installer's command line.
+// Arguments following "--" are forwarded to the installed application.
+func parseBootstrapOptions(args []string) (bootstrapOptions, error) {
+	var opts bootstrapOptions
+
+	flags := flag.NewFlagSet(os.Args[0], flag.ContinueOnError)
+	flags.StringVar(&opts.installDir, "install-dir", "", "directory to install into (defaults to the installDir setting or a per-user location)")
+	flags.BoolVar(&opts.repair, "repair", false, "restore missing or modified files and packages of an existing installation")
+	flags.BoolVar(&opts.uninstall, "uninstall", false, "remove the files recorded in the install log")
+	flags.BoolVar(&opts.dryRun, "dry-run", false, "with --uninstall, list the files that would be removed without removing them")
+	flags.BoolVar(&opts.yes, "yes", false, "answer yes to every question, for unattended installations")
+	flags.BoolVar(&opts.noRun, "no-run", false, "do not run the application after installation, even if runAfterInstall is set")
+	flags.BoolVar(&opts.offline, "offline", false, "install requirements only from the wheels in the installer, never from the package index")
+
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	opts.appArgs = flags.Args()
+	return opts, nil
+}
+
+// configOptions selects the configuration file, and the overrides applied to it, for the creator commands that load it.
+type configOptions struct {
+	// configFile is the configuration file to use; empty looks for one in the working directory.
+	configFile string
+	// set holds key=value overrides of individual settings.
+	set stringList
+}
+
+func (opts *configOptions) register(flags *flag.FlagSet) {
+	flags.StringVar(&opts.configFile, "config", "", "configuration file (exepy.json, exepy.yaml or exepy.toml in the working directory by default)")
+	flags.Var(&opts.set, "set", "override a setting with key=value; may be repeated")
+}
+
+// buildOptions holds the options of the build command.
+type buildOptions struct {
+	configOptions
+	// out is the path of the installer to write; empty uses the outputName setting.
+	out string
+	// stub is the bootstrapper executable the attachments are embedded into; empty uses this executable.
+	stub string
+	// noCache builds the Python runtime and wheels from scratch without reading or writing the build cache.
+	noCache bool
+	// test installs the built installer into a temporary directory and runs the smoke test there.
+	test bool
+	// testTimeout limits the installation and the smoke test each.
+	testTimeout time.Duration
+}
+
+// parseBuildOptions parses the arguments of the build command.
+func parseBuildOptions(args []string) (buildOptions, error) {
+	var opts buildOptions
+
+	flags := flag.NewFlagSet("build", flag.ContinueOnError)
+	opts.register(flags)
+	flags.StringVar(&opts.out, "out", "", "path of the installer to write; may use {{key}} placeholders like outputName (defaults to the outputName setting)")
+	flags.StringVar(&opts.stub, "stub", "", "bootstrapper executable to embed the installer into (defaults to this executable)")
+	flags.BoolVar(&opts.noCache, "no-cache", false, "do not reuse or store prepared Python runtimes and wheels")
+	flags.BoolVar(&opts.test, "test", false, "install the built installer into a temporary directory and run the smoke test")
+	flags.DurationVar(&opts.testTimeout, "test-timeout", 10*time.Minute, "time limit of the test installation and of the smoke test")
+
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if flags.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	return opts, nil
+}
+
+// parseConfigOptions parses the arguments of a command that only loads the configuration.
+func parseConfigOptions(name string, args []string) (configOptions, error) {
+	var opts configOptions
+
+	flags := flag.NewFlagSet(name, flag.ContinueOnError)
+	opts.register(flags)
+
+	if err := flags.Parse(args); err != nil {
+		return opts, err
+	}
+
+	if flags.NArg() > 0 {
+		return opts, fmt.Errorf("unexpected arguments: %v", flags.Args())
+	}
+
+	return opts, nil
+}
+
+// stringList is a flag that may be given several times.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ", ")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
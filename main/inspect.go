@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"github.com/maja42/ember"
+	"io"
+	"lukasolson.net/common"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+)
+
+// archiveAttachments are the attachments holding compressed directory streams rather than plain files.
+var archiveAttachments = []string{
+	common.PythonFilename,
+	common.ScriptsFilename,
+	common.WheelsFolderName,
+	common.CopyToRootFilename,
+	common.PayloadFilename,
+}
+
+// openInstaller parses the arguments of a command that takes an installer as its first positional argument, and opens its attachments.
+func openInstaller(name string, args []string, flags *flag.FlagSet, positional int) (*ember.Attachments, []string, error) {
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	if flags.NArg() != positional {
+		flags.Usage()
+		return nil, nil, fmt.Errorf("%s expects %d arguments, got %d", name, positional, flags.NArg())
+	}
+
+	attachments, err := ember.OpenExe(flags.Arg(0))
+	if err != nil {
+		return nil, nil, fmt.Errorf("error opening %s: %w", flags.Arg(0), err)
+	}
+
+	if attachments.Count() == 0 {
+		attachments.Close()
+		return nil, nil, fmt.Errorf("%s has no attachments; it is not a built installer", flags.Arg(0))
+	}
+
+	return attachments, flags.Args(), nil
+}
+
+// inspectInstaller lists the attachments of an installer with their sizes and hashes, and prints its settings.
+func inspectInstaller(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	attachments, positional, err := openInstaller("inspect", args, flags, 1)
+	if err != nil {
+		return err
+	}
+	defer attachments.Close()
+
+	fmt.Println("Installer:", positional[0])
+
+	hashMap, err := GetHashmap(attachments)
+	if err != nil {
+		fmt.Println("Warning: no readable hashmap; attachment hashes cannot be checked")
+	}
+
+	names := attachments.List()
+	sort.Strings(names)
+
+	fmt.Println()
+	fmt.Printf("%-20s %12s  %-32s  %s\n", "ATTACHMENT", "SIZE", "MD5", "STATUS")
+	for _, name := range names {
+		hash, err := common.HashReadSeeker(attachments.Reader(name))
+		if err != nil {
+			return fmt.Errorf("error hashing %s: %w", name, err)
+		}
+
+		status := "ok"
+		switch expected, found := hashMap[name]; {
+		case name == common.HashmapName:
+			status = "-"
+		case !found:
+			status = "not in hashmap"
+		case expected != hash:
+			status = "MISMATCH (expected " + expected + ")"
+		}
+
+		fmt.Printf("%-20s %12s  %-32s  %s\n", name, formatSize(attachments.Size(name)), hash, status)
+	}
+
+	if digests, err := GetInstallDigests(attachments); err == nil {
+		components := make([]string, 0, len(digests))
+		for component := range digests {
+			components = append(components, component)
+		}
+		sort.Strings(components)
+
+		fmt.Println()
+		fmt.Println("Files per component:")
+		for _, component := range components {
+			fmt.Printf("  %-18s %d\n", component, len(digests[component]))
+		}
+	}
+
+	if err := printSBOM(attachments); err != nil {
+		return err
+	}
+
+	settings, err := GetSettings(attachments)
+	if err != nil {
+		return fmt.Errorf("error reading settings: %w", err)
+	}
+
+	settingsJson, err := json.MarshalIndent(settings, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	fmt.Println()
+	fmt.Println("Settings:")
+	fmt.Println(string(settingsJson))
+
+	return nil
+}
+
+// verifyInstaller checks an installer without installing it: every attachment must match the hashmap,
+// and every archive must unpack to exactly the files and contents recorded in the install digests.
+func verifyInstaller(args []string) error {
+	flags := flag.NewFlagSet("verify", flag.ContinueOnError)
+	attachments, positional, err := openInstaller("verify", args, flags, 1)
+	if err != nil {
+		return err
+	}
+	defer attachments.Close()
+
+	fmt.Println("Verifying", positional[0])
+
+	var problems []error
+
+	if !ValidateAttachmentHashes(attachments) {
+		problems = append(problems, errors.New("attachment hashes do not match the hashmap"))
+	} else {
+		fmt.Println("Attachment hashes: ok")
+	}
+
+	if _, err := GetSettings(attachments); err != nil {
+		problems = append(problems, fmt.Errorf("settings are unreadable: %w", err))
+	}
+
+	digests, err := GetInstallDigests(attachments)
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	tempDir, err := os.MkdirTemp("", "exepy-verify-*")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(tempDir)
+
+	for _, component := range archiveAttachments {
+		reader := attachments.Reader(component)
+		if reader == nil {
+			continue
+		}
+
+		componentDir := filepath.Join(tempDir, component)
+		if err := common.StreamToDir(reader, componentDir); err != nil {
+			problems = append(problems, fmt.Errorf("%s: archive is corrupt: %w", component, err))
+			continue
+		}
+
+		if digests == nil {
+			continue
+		}
+
+		report, err := common.VerifyDirectoryIntegrity(componentDir, digests[component], common.IntegrityOptions{DetectAdded: true})
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", component, err))
+			continue
+		}
+
+		if !report.IsIntact() {
+			printIntegrityFiles("Modified files in "+component+":", report.Modified)
+			printIntegrityFiles("Missing files in "+component+":", report.Missing)
+			printIntegrityFiles("Unexpected files in "+component+":", report.Added)
+			problems = append(problems, fmt.Errorf("%s: contents do not match the install digests", component))
+			continue
+		}
+
+		fmt.Printf("%s: ok (%d files)\n", component, len(digests[component]))
+	}
+
+	if len(problems) > 0 {
+		return fmt.Errorf("installer verification failed:\n%w", errors.Join(problems...))
+	}
+
+	fmt.Println("Installer verified successfully.")
+	return nil
+}
+
+// extractInstaller writes the attachments of an installer to a directory.
+// Archives are unpacked into a folder named after the attachment unless --raw is given.
+func extractInstaller(args []string) error {
+	flags := flag.NewFlagSet("extract", flag.ContinueOnError)
+	raw := flags.Bool("raw", false, "write archives as they are embedded instead of unpacking them")
+
+	attachments, positional, err := openInstaller("extract", args, flags, 2)
+	if err != nil {
+		return err
+	}
+	defer attachments.Close()
+
+	outputDir := positional[1]
+	if err := os.MkdirAll(outputDir, os.ModePerm); err != nil {
+		return err
+	}
+
+	for _, name := range attachments.List() {
+		reader := attachments.Reader(name)
+		target := filepath.Join(outputDir, name)
+
+		if !*raw && slices.Contains(archiveAttachments, name) {
+			if err := common.StreamToDir(reader, target); err != nil {
+				return fmt.Errorf("error unpacking %s: %w", name, err)
+			}
+			fmt.Println("Unpacked", name, "to", target)
+			continue
+		}
+
+		if err := writeAttachment(target, reader); err != nil {
+			return fmt.Errorf("error writing %s: %w", name, err)
+		}
+		fmt.Println("Wrote", name, "to", target)
+	}
+
+	return nil
+}
+
+// printSBOM summarizes the software bill of materials of an installer: its runtime and packages, and the number of files.
+func printSBOM(attachments *ember.Attachments) error {
+	reader := attachments.Reader(common.SBOMFilename)
+	if reader == nil {
+		fmt.Println()
+		fmt.Println("No SBOM embedded.")
+		return nil
+	}
+
+	var bom common.CycloneDXBOM
+	if err := json.NewDecoder(reader).Decode(&bom); err != nil {
+		return fmt.Errorf("error reading SBOM: %w", err)
+	}
+
+	fmt.Println()
+	fmt.Printf("SBOM (%s %s):\n", bom.BOMFormat, bom.SpecVersion)
+
+	files := 0
+	for _, component := range bom.Components {
+		if component.Type == "file" {
+			files++
+			continue
+		}
+		fmt.Printf("  %-9s %-30s %s\n", component.Type, component.Name, component.Version)
+	}
+	fmt.Printf("  %d application files\n", files)
+
+	return nil
+}
+
+func writeAttachment(target string, reader io.Reader) error {
+	file, err := os.Create(target)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	if _, err := io.Copy(file, reader); err != nil {
+		return err
+	}
+
+	return file.Close()
+}
+
+// formatSize formats a byte count for display.
+func formatSize(size int64) string {
+	const unit = 1024
+	if size < unit {
+		return fmt.Sprintf("%d B", size)
+	}
+
+	value := float64(size)
+	suffix := ""
+	for _, s := range []string{"KiB", "MiB", "GiB", "TiB"} {
+		value /= unit
+		suffix = s
+		if value < unit {
+			break
+		}
+	}
+
+	return fmt.Sprintf("%.1f %s", value, suffix)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/maja42/ember"
+	"github.com/maja42/ember/embedding"
+	"golang.org/x/sys/windows"
+	"io"
+	"lukasolson.net/common"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"windowsPE"
+)
+
+// loadLauncherConfig returns the launcher configuration embedded in this executable, if it is a launcher.
+func loadLauncherConfig() (common.LauncherConfig, bool) {
+	var config common.LauncherConfig
+
+	attachments, err := ember.Open()
+	if err != nil {
+		return config, false
+	}
+	defer attachments.Close()
+
+	reader := attachments.Reader(common.LauncherConfigName)
+	if reader == nil {
+		return config, false
+	}
+
+	if err := json.NewDecoder(reader).Decode(&config); err != nil {
+		fmt.Println("Error reading launcher configuration:", err)
+		return config, false
+	}
+
+	return config, true
+}
+
+// runLauncher starts the application with the embedded Python, forwarding the arguments, and returns its exit code.
+// Paths are resolved relative to the launcher, so they may contain any character and the installation may be moved.
+func runLauncher(config common.LauncherConfig) int {
+	self, err := os.Executable()
+	if err == nil {
+		self, err = filepath.EvalSymlinks(self)
+	}
+	if err != nil {
+		launcherError(config, err)
+		return 1
+	}
+
+	python, script, _ := config.Resolve(filepath.Dir(self))
+
+	for _, required := range []string{python, script} {
+		if required != "" && !common.DoesPathExist(required) {
+			launcherError(config, fmt.Errorf("%s not found; reinstall %s to repair the installation", required, config.Title))
+			return 1
+		}
+	}
+
+	cmd := exec.Command(python, config.PythonArguments(script, os.Args[1:])...)
+	cmd.Dir = config.ResolveWorkingDirectory(filepath.Dir(self))
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.Env = launcherEnvironment(os.Environ(), config.Env)
+
+	// Ctrl+C reaches the application too; the launcher waits for it to exit rather than dying first
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+
+	if err := cmd.Run(); err != nil {
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) {
+			return exitErr.ExitCode()
+		}
+		launcherError(config, err)
+		return 1
+	}
+
+	return 0
+}
+
+// launcherEnvironment returns the environment of the application: variables that would make a Python started by
+// the application load another installation's modules are removed, and the variables of the entry point are set.
+// The embedded Python finds the scripts directory through its ._pth file, which also makes it ignore these variables.
+func launcherEnvironment(environ []string, extra map[string]string) []string {
+	return common.MergeEnvironment(environ, []string{"PYTHONHOME", "PYTHONPATH"}, extra)
+}
+
+// launcherError reports an error that prevents the application from starting. A windowed launcher has no console,
+// so it shows a message box instead.
+func launcherError(config common.LauncherConfig, err error) {
+	if !config.Windowed {
+		fmt.Fprintln(os.Stderr, "Error starting "+config.Title+":", err)
+		return
+	}
+
+	text, _ := windows.UTF16PtrFromString(err.Error())
+	caption, _ := windows.UTF16PtrFromString(config.Title)
+	_, _ = windows.MessageBox(0, text, caption, windows.MB_OK|windows.MB_ICONERROR)
+}
+
+// buildLauncherStub returns the bootstrapper at stubPath without signature or attachments. Installers embed it
+// and turn it into a launcher for each entry point by attaching the entry point's configuration.
+func buildLauncherStub(stubPath string) ([]byte, error) {
+	executableBytes, err := loadStub(stubPath)
+	if err != nil {
+		return nil, err
+	}
+
+	exeWithoutSignature, err := windowsPE.RemoveSignature(executableBytes)
+	if err != nil {
+		return nil, err
+	}
+
+	return removeEmbedding(exeWithoutSignature)
+}
+
+// makeLauncher attaches a launcher configuration to a launcher stub, giving windowed launchers the GUI subsystem
+// so Windows does not open a console for them.
+func makeLauncher(stub []byte, config common.LauncherConfig) ([]byte, error) {
+	subsystem := windowsPE.SubsystemWindowsCUI
+	if config.Windowed {
+		subsystem = windowsPE.SubsystemWindowsGUI
+	}
+
+	launcherBytes, err := windowsPE.SetSubsystem(bytes.Clone(stub), subsystem)
+	if err != nil {
+		return nil, err
+	}
+
+	configJson, err := json.Marshal(config)
+	if err != nil {
+		return nil, err
+	}
+
+	var launcher bytes.Buffer
+	attachments := map[string]io.ReadSeeker{common.LauncherConfigName: bytes.NewReader(configJson)}
+	if err := embedding.Embed(&launcher, bytes.NewReader(launcherBytes), attachments, nil); err != nil {
+		return nil, err
+	}
+
+	return launcher.Bytes(), nil
+}
+
+// installLaunchers writes a launcher for each entry point to the install root and returns their paths by entry point name.
+func installLaunchers(attachments *ember.Attachments, installRoot string, settings common.PythonSetupSettings) (map[string]string, error) {
+	reader := attachments.Reader(common.LauncherFilename)
+	if reader == nil {
+		return nil, fmt.Errorf("the installer contains no launcher")
+	}
+
+	stub, err := io.ReadAll(reader)
+	if err != nil {
+		return nil, err
+	}
+
+	launchers := make(map[string]string)
+	for name, entryPoint := range settings.ResolvedEntryPoints() {
+		launcher, err := makeLauncher(stub, common.NewLauncherConfig(&settings, name, entryPoint))
+		if err != nil {
+			return nil, fmt.Errorf("error creating launcher %s: %w", name, err)
+		}
+
+		launcherPath := common.LauncherPath(installRoot, name)
+		if err := os.WriteFile(launcherPath, launcher, 0755); err != nil {
+			return nil, err
+		}
+
+		launchers[name] = launcherPath
+	}
+
+	return launchers, nil
+}
@@ -2,6 +2,8 @@ package main
 
 import (
 	_ "embed"
+	"errors"
+	"flag"
 	"fmt"
 	"github.com/maja42/ember"
 	"io"
@@ -11,6 +13,11 @@ import (
 
 func main() {
 
+	// a launcher only starts the installed application
+	if config, ok := loadLauncherConfig(); ok {
+		os.Exit(runLauncher(config))
+	}
+
 	defer func() {
 		// Check if a panic occurred.
 		code := 0
@@ -29,6 +36,11 @@ func main() {
 
 			os.Exit(code)
 		}
+
+		// report the failure to scripts and to build --test
+		if code != 0 {
+			os.Exit(code)
+		}
 	}()
 
 	embedded, err := checkIfEmbedded()
@@ -43,15 +55,27 @@ func main() {
 	}
 
 	if embedded {
-		err := bootstrap()
+		opts, err := parseBootstrapOptions(os.Args[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
 		if err != nil {
 			panic(err)
 		}
-	} else {
-		PrintHeader() // Only print the header if we're in creator mode.
 
-		fmt.Println("No scripts have been embedded. Running in creator mode.")
-		err = createInstaller()
+		if opts.uninstall {
+			err = uninstall(opts)
+		} else {
+			err = bootstrap(opts)
+		}
+		if err != nil {
+			panic(err)
+		}
+	} else {
+		err = runCreatorCommand(os.Args[1:])
+		if errors.Is(err, flag.ErrHelp) {
+			return
+		}
 		if err != nil {
 			panic(err)
 		}
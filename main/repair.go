@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"github.com/maja42/ember"
+	"lukasolson.net/common"
+	"path/filepath"
+	"sort"
+)
+
+// repairInstallation restores the extracted files and installed packages that no longer match the installer.
+// Only the damaged paths are re-extracted from the embedded archives; the result is validated again afterward.
+func repairInstallation(attachments *ember.Attachments, runner *common.CommandRunner, settings *common.PythonSetupSettings, componentDirs map[string]string, installDigests common.InstallDigests) error {
+	fmt.Println("Checking installation for missing or modified files...")
+
+	damagedFiles, err := findDamagedFiles(componentDirs, installDigests)
+	if err != nil {
+		return err
+	}
+
+	if err := restoreFiles(attachments, componentDirs, damagedFiles); err != nil {
+		return err
+	}
+
+	pythonExtractDir := componentDirs[common.PythonFilename]
+	sitePackagesDir := filepath.Join(pythonExtractDir, "Lib", "site-packages")
+
+	damagedPackages, err := common.VerifyInstalledPackages(sitePackagesDir)
+	if err != nil {
+		return fmt.Errorf("error verifying installed packages: %w", err)
+	}
+
+	if len(damagedPackages) > 0 {
+		packages := common.DamagedPackageNames(damagedPackages)
+		for _, name := range packages {
+			fmt.Printf("Package %s has %d missing or modified files\n", name, len(damagedPackages[name]))
+		}
+
+		if err := reinstallPackages(runner, settings, pythonExtractDir, packages); err != nil {
+			return err
+		}
+	}
+
+	if len(damagedFiles) == 0 && len(damagedPackages) == 0 {
+		fmt.Println("No damaged files found.")
+		return nil
+	}
+
+	// validate again to confirm the repair worked
+	damagedFiles, err = findDamagedFiles(componentDirs, installDigests)
+	if err != nil {
+		return err
+	}
+
+	damagedPackages, err = common.VerifyInstalledPackages(sitePackagesDir)
+	if err != nil {
+		return fmt.Errorf("error verifying installed packages: %w", err)
+	}
+
+	if len(damagedFiles) > 0 || len(damagedPackages) > 0 {
+		return fmt.Errorf("repair failed: %d components and %d packages are still damaged", len(damagedFiles), len(damagedPackages))
+	}
+
+	fmt.Println("Installation repaired successfully.")
+	return nil
+}
+
+// findDamagedFiles compares each extracted component with its embedded digests.
+// It returns the mismatched paths of every component that has any.
+func findDamagedFiles(componentDirs map[string]string, installDigests common.InstallDigests) (map[string][]string, error) {
+	damaged := make(map[string][]string)
+
+	for component, dir := range componentDirs {
+		report, err := common.VerifyDirectoryIntegrity(dir, installDigests[component], common.IntegrityOptions{})
+		if err != nil {
+			return nil, fmt.Errorf("error verifying %s: %w", component, err)
+		}
+
+		mismatched := report.Mismatched()
+
+		if len(mismatched) > 0 {
+			damaged[component] = mismatched
+		}
+	}
+
+	return damaged, nil
+}
+
+// restoreFiles re-extracts only the given paths of each component from the embedded archives.
+func restoreFiles(attachments *ember.Attachments, componentDirs map[string]string, damaged map[string][]string) error {
+	components := make([]string, 0, len(damaged))
+	for component := range damaged {
+		components = append(components, component)
+	}
+	sort.Strings(components)
+
+	for _, component := range components {
+		fmt.Printf("Restoring %d files in %s:\n", len(damaged[component]), component)
+
+		restore := make(map[string]bool, len(damaged[component]))
+		for _, relativePath := range damaged[component] {
+			fmt.Println(" - " + relativePath)
+			restore[relativePath] = true
+		}
+
+		reader := attachments.Reader(component)
+		if reader == nil {
+			return fmt.Errorf("error reading %s. Ensure it is embedded in the binary", component)
+		}
+
+		err := common.StreamToDirFiltered(reader, componentDirs[component], func(relativePath string) bool {
+			return restore[relativePath]
+		})
+		if err != nil {
+			return fmt.Errorf("error restoring %s files: %w", component, err)
+		}
+	}
+
+	return nil
+}
+
+// reinstallPackages reinstalls the given distributions from the embedded wheels without touching their dependencies.
+func reinstallPackages(runner *common.CommandRunner, settings *common.PythonSetupSettings, pythonExtractDir string, packages []string) error {
+	wheelsDir := filepath.Join(pythonExtractDir, common.WheelsFolderName)
+
+	args := []string{
+		"--no-index",
+		"--find-links", filepath.Join(wheelsDir, "required"),
+		"--find-links", filepath.Join(wheelsDir, "setup"),
+		"--force-reinstall",
+		"--no-deps",
+	}
+	args = append(args, packages...)
+
+	if err := pipInstall(runner, settings, pythonExtractDir, args...); err != nil {
+		return fmt.Errorf("error reinstalling packages: %w", err)
+	}
+
+	return nil
+}
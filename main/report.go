@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"lukasolson.net/common"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// buildReport describes a built installer for release pipelines.
+type buildReport struct {
+	Output          string             `json:"output"`
+	Size            int64              `json:"size"`
+	Sha256          string             `json:"sha256"`
+	Md5             string             `json:"md5"`
+	BuiltAt         time.Time          `json:"builtAt"`
+	DurationSeconds float64            `json:"durationSeconds"`
+	ApplicationName string             `json:"applicationName"`
+	Version         string             `json:"version"`
+	PythonURL       string             `json:"pythonDownloadURL"`
+	Attachments     []attachmentReport `json:"attachments"`
+	// Files counts the files in each archive attachment.
+	Files map[string]int `json:"files"`
+}
+
+type attachmentReport struct {
+	Name string `json:"name"`
+	Size int64  `json:"size"`
+	Md5  string `json:"md5"`
+}
+
+// sidecarPath returns the path of a file written next to the installer, replacing its extension.
+func sidecarPath(outputPath string, extension string) string {
+	return strings.TrimSuffix(outputPath, filepath.Ext(outputPath)) + extension
+}
+
+// writeBuildOutputs writes the checksum file, in sha256sum format, and the JSON build report next to the installer.
+func writeBuildOutputs(outputPath string, settings *common.PythonSetupSettings, embedMap map[string]io.ReadSeeker, hashMap map[string]string, installDigests common.InstallDigests, startTime time.Time) error {
+	info, err := os.Stat(outputPath)
+	if err != nil {
+		return err
+	}
+
+	sha256sum, err := common.Sha256SumFile(outputPath)
+	if err != nil {
+		return err
+	}
+
+	md5sum, err := common.Md5SumFile(outputPath)
+	if err != nil {
+		return err
+	}
+
+	// "<hash>  <name>" lets `sha256sum -c` check the installer from its directory
+	checksumPath := outputPath + ".sha256"
+	checksum := fmt.Sprintf("%s  %s\n", sha256sum, filepath.Base(outputPath))
+	if err := os.WriteFile(checksumPath, []byte(checksum), 0644); err != nil {
+		return err
+	}
+
+	report := buildReport{
+		Output:          filepath.Base(outputPath),
+		Size:            info.Size(),
+		Sha256:          sha256sum,
+		Md5:             md5sum,
+		BuiltAt:         time.Now().UTC().Truncate(time.Second),
+		DurationSeconds: time.Since(startTime).Round(time.Millisecond).Seconds(),
+		ApplicationName: *settings.ApplicationName,
+		Version:         *settings.Version,
+		PythonURL:       *settings.PythonDownloadURL,
+		Files:           make(map[string]int, len(installDigests)),
+	}
+
+	for name, reader := range embedMap {
+		size, err := reader.Seek(0, io.SeekEnd)
+		if err != nil {
+			return err
+		}
+		if _, err := reader.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+
+		report.Attachments = append(report.Attachments, attachmentReport{Name: name, Size: size, Md5: hashMap[name]})
+	}
+
+	sort.Slice(report.Attachments, func(i, j int) bool {
+		return report.Attachments[i].Name < report.Attachments[j].Name
+	})
+
+	for component, hashes := range installDigests {
+		report.Files[component] = len(hashes)
+	}
+
+	// the SBOM is embedded for inspect and written alongside for compliance tooling
+	sbomPath := sidecarPath(outputPath, ".cdx.json")
+	if sbom, ok := embedMap[common.SBOMFilename]; ok {
+		if err := writeAttachment(sbomPath, sbom); err != nil {
+			return err
+		}
+		if _, err := sbom.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+	}
+
+	reportJson, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	reportPath := sidecarPath(outputPath, ".build.json")
+	if err := os.WriteFile(reportPath, reportJson, 0644); err != nil {
+		return err
+	}
+
+	fmt.Println("Installer SHA-256:", sha256sum)
+	fmt.Println("Wrote", filepath.Base(checksumPath)+",", filepath.Base(sbomPath), "and", filepath.Base(reportPath))
+
+	return nil
+}
@@ -2,17 +2,15 @@
 
 # -----------------------------------------------------------------------------
 # run.sh
-# This shell script is auto-generated by the installer to run the Python script using the specified Python executable.
-# The following variables are set by the installer:
-#   {{PYTHON_EXE}}   - Absolute path to the Python executable.
-#   {{MAIN_SCRIPT}}  - Absolute path to the main Python script.
-#   {{SCRIPTS_DIR}}  - Absolute path to the scripts directory.
+# This shell script is auto-generated by the installer to run the application using the embedded Python.
+# The following placeholders are filled in by the installer:
+#   {{PYTHON_EXE}}        - Absolute path to the Python executable.
+#   {{ENVIRONMENT}}       - Environment variables of the application.
+#   {{CHANGE_DIRECTORY}}  - Change to the working directory of the application, if it has one.
+#   {{PYTHON_ARGUMENTS}}  - Interpreter flags followed by the script, or -m and the module, to run.
 # -----------------------------------------------------------------------------
 
-# Convert the placeholders to absolute paths (if they are relative).
-PYTHON_EXE="$(realpath "{{PYTHON_EXE}}")"
-MAIN_SCRIPT="$(realpath "{{MAIN_SCRIPT}}")"
-SCRIPTS_DIR="$(realpath "{{SCRIPTS_DIR}}")"
+PYTHON_EXE={{PYTHON_EXE}}
 
 # Check if the Python executable exists.
 if [ ! -x "$PYTHON_EXE" ]; then
@@ -20,24 +18,15 @@ if [ ! -x "$PYTHON_EXE" ]; then
     exit 1
 fi
 
-# Check if the main Python script exists.
-if [ ! -f "$MAIN_SCRIPT" ]; then
-    echo "Error: Main Python script not found: $MAIN_SCRIPT"
-    exit 1
-fi
-
-# Convert backslashes to forward slashes for Python compatibility.
-SCRIPTS_DIR_MOD="$SCRIPTS_DIR"
-MAIN_SCRIPT_MOD="$MAIN_SCRIPT"
-
-# Set the PYTHONPATH environment variable.
-export PYTHONPATH="$SCRIPTS_DIR"
-
-# Construct the inline Python code as a single line.
-PY_CODE="import sys; sys.path.insert(0, '$SCRIPTS_DIR_MOD'); sys.argv = ['$MAIN_SCRIPT_MOD'] + sys.argv[1:]; f = open('$MAIN_SCRIPT_MOD', 'r'); code = f.read(); f.close(); globals_dict = {'__file__': '$MAIN_SCRIPT_MOD', '__name__': '__main__'}; exec(compile(code, '$MAIN_SCRIPT_MOD', 'exec'), globals_dict)"
+# Keep the settings of other Python installations from reaching the application and the programs it starts.
+# The scripts directory is on the module search path through the ._pth file of the embedded Python.
+unset PYTHONHOME
+unset PYTHONPATH
+{{ENVIRONMENT}}
+{{CHANGE_DIRECTORY}}
 
-# Execute the Python command and pass all additional arguments.
-"$PYTHON_EXE" -c "$PY_CODE" "$@"
+# Run the application like "python script.py" or "python -m module" and pass all additional arguments.
+"$PYTHON_EXE" {{PYTHON_ARGUMENTS}} "$@"
 PY_EXIT_CODE=$?
 
 if [ $PY_EXIT_CODE -ne 0 ]; then
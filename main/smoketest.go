@@ -0,0 +1,107 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"lukasolson.net/common"
+	"os"
+	"path/filepath"
+	"regexp"
+	"time"
+)
+
+// smokeTestNetworkGuard keeps the test installation off the network: pip may not use a package index, and every HTTP
+// and HTTPS request goes to a proxy on the discard port, where nothing listens, so it fails at once.
+var smokeTestNetworkGuard = map[string]string{
+	"PIP_NO_INDEX": "1",
+	"HTTP_PROXY":   "http://127.0.0.1:9",
+	"HTTPS_PROXY":  "http://127.0.0.1:9",
+	"NO_PROXY":     "",
+}
+
+// smokeTest installs the installer at installerPath into a temporary directory, with pip limited to the wheels in the
+// installer, runs the smoke test there and removes the directory again. Each step is limited to timeout.
+func smokeTest(installerPath string, settings *common.PythonSetupSettings, timeout time.Duration) error {
+	testRoot, err := os.MkdirTemp("", "exepy-test-*")
+	if err != nil {
+		fmt.Println("Error creating test directory:", err)
+		return err
+	}
+	defer func() {
+		if err := os.RemoveAll(testRoot); err != nil {
+			fmt.Println("Warning: could not remove test directory:", err)
+		}
+	}()
+
+	fmt.Println("Testing the installer in", testRoot)
+
+	installer := common.CommandRunner{Timeout: timeout, Env: smokeTestNetworkGuard}
+	if err := installer.Run(installerPath, "--install-dir", testRoot, "--yes", "--offline", "--no-run"); err != nil {
+		return fmt.Errorf("test installation failed: %w", err)
+	}
+
+	runner, command, args, ok := smokeTestCommand(testRoot, settings)
+	if !ok {
+		fmt.Println("Installation succeeded. Set smokeTestArgs or smokeTestScript to also run the application.")
+		return nil
+	}
+	runner.Timeout = timeout
+
+	output, err := runner.Output(command, args...)
+	os.Stdout.Write(output)
+	if err != nil {
+		var commandErr *common.CommandError
+		if errors.As(err, &commandErr) && commandErr.ExitCode >= 0 {
+			return fmt.Errorf("the application exited with code %d", commandErr.ExitCode)
+		}
+		return err
+	}
+
+	if *settings.SmokeTestOutput != "" && !regexp.MustCompile(*settings.SmokeTestOutput).Match(output) {
+		return fmt.Errorf("the output does not match smokeTestOutput %q", *settings.SmokeTestOutput)
+	}
+
+	fmt.Println("Smoke test passed.")
+	return nil
+}
+
+// smokeTestCommand returns how to run the smoke test in an installation: smokeTestScript with the embedded Python,
+// or else the default entry point with smokeTestArgs. ok is false when there is nothing to run.
+func smokeTestCommand(installRoot string, settings *common.PythonSetupSettings) (runner common.CommandRunner, command string, args []string, ok bool) {
+	runner.Dir = installRoot
+	scriptDir := filepath.Join(installRoot, *settings.ScriptExtractDir)
+	python := filepath.Join(installRoot, *settings.PythonExtractDir, "python.exe")
+
+	if script := *settings.SmokeTestScript; script != "" {
+		return runner, python, append([]string{filepath.Join(scriptDir, script)}, settings.SmokeTestArgs...), true
+	}
+
+	if len(settings.SmokeTestArgs) == 0 {
+		return runner, "", nil, false
+	}
+
+	name := settings.DefaultEntryPointName()
+	if name == "" {
+		if _, hasMain := settings.MainEntryPoint(); !hasMain || len(settings.ResolvedEntryPoints()) > 0 {
+			return runner, "", nil, false
+		}
+		return runner, runScriptPath(installRoot, *settings.RunScriptFileStem), settings.SmokeTestArgs, true
+	}
+
+	entryPoint := settings.ResolvedEntryPoints()[name]
+	if config := common.NewLauncherConfig(settings, name, entryPoint); !config.Windowed {
+		return runner, common.LauncherPath(installRoot, name), settings.SmokeTestArgs, true
+	}
+
+	// pythonw.exe has no output to check, so a windowed entry point is run the way its console launcher would run it
+	entryPoint.Launcher = common.LauncherConsole
+	config := common.NewLauncherConfig(settings, name, entryPoint)
+	python, script, _ := config.Resolve(installRoot)
+
+	runner.Env = config.Env
+	if dir := config.ResolveWorkingDirectory(installRoot); dir != "" {
+		runner.Dir = dir
+	}
+
+	return runner, python, config.PythonArguments(script, settings.SmokeTestArgs), true
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"github.com/maja42/ember"
+	"lukasolson.net/common"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// startInstallLog records every file the bootstrapper is about to place in the install root and saves the log before
+// anything is written, so an installation that stops partway can still be uninstalled. The files of an earlier
+// installation in the same root stay listed.
+func startInstallLog(installRoot string, componentDirs map[string]string, installDigests common.InstallDigests, settings common.PythonSetupSettings) (*common.InstallLog, error) {
+	installLog, err := common.LoadInstallLog(installRoot)
+	if os.IsNotExist(err) {
+		installLog = common.NewInstallLog(installRoot)
+	} else if err != nil {
+		return nil, err
+	}
+
+	for component, dir := range componentDirs {
+		installLog.RecordHashes(dir, installDigests[component])
+	}
+
+	for _, file := range settings.FilesToCopyToRoot {
+		if installDigests.Lookup(common.ScriptsFilename, file) != "" {
+			installLog.Record(filepath.Join(installRoot, file))
+		}
+	}
+
+	installLog.Record(bootstrappedFileName)
+	installLog.Record(common.InstallManifestFilename)
+	installLog.Record(common.InstallOutputLogFilename)
+
+	if _, hasMain := settings.MainEntryPoint(); hasMain {
+		installLog.Record(runScriptPath(installRoot, *settings.RunScriptFileStem))
+	}
+	for _, name := range settings.EntryPointNames() {
+		installLog.Record(common.LauncherPath(installRoot, name))
+	}
+
+	return installLog, installLog.Save()
+}
+
+// finishInstallLog adds the packages pip installed into the embedded Python, which cannot be known in advance, and
+// drops the files that were never written. It runs however the installation ends.
+func finishInstallLog(installLog *common.InstallLog, componentDirs map[string]string) error {
+	sitePackagesDir := filepath.Join(componentDirs[common.PythonFilename], "Lib", "site-packages")
+	if err := installLog.RecordPipInstalls(sitePackagesDir); err != nil {
+		return err
+	}
+
+	installLog.RemoveMissing()
+	return installLog.Save()
+}
+
+// uninstall removes exactly the files listed in the install log, leaving anything else in the install root alone.
+func uninstall(opts bootstrapOptions) error {
+	attachments, err := ember.Open()
+	if err != nil {
+		return err
+	}
+	defer attachments.Close()
+
+	settings, err := GetSettings(attachments)
+	if err != nil {
+		return err
+	}
+
+	installRoot, err := common.ResolveInstallRoot(opts.installDir, &settings)
+	if err != nil {
+		return err
+	}
+
+	installLog, err := common.LoadInstallLog(installRoot)
+	if os.IsNotExist(err) {
+		return fmt.Errorf("no install log found in %s", installRoot)
+	}
+	if err != nil {
+		return err
+	}
+
+	files := installLog.Files()
+
+	if opts.dryRun {
+		fmt.Println("The following files would be removed from", installRoot+":")
+		for _, file := range files {
+			fmt.Println(" - " + file)
+		}
+		return nil
+	}
+
+	fmt.Println("Uninstalling from", installRoot)
+
+	failed := 0
+	for _, file := range files {
+		// bytecode goes first, so its __pycache__ directory does not keep the module's directory from being removed
+		removeCompiledBytecode(installRoot, file)
+
+		if err := common.RemoveFileAndEmptyParents(installRoot, file); err != nil {
+			fmt.Println("Error removing", file+":", err)
+			failed++
+		}
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d files could not be removed", failed)
+	}
+
+	fmt.Println("Removed", len(files), "files.")
+
+	if remaining, err := common.ListFilesInDir(installRoot); err == nil && len(remaining) > 0 {
+		fmt.Println("Files not created by the installer were left in", installRoot)
+	}
+
+	return nil
+}
+
+// removeCompiledBytecode removes the __pycache__ entries Python generated for a removed module.
+func removeCompiledBytecode(installRoot string, relativePath string) {
+	if !strings.HasSuffix(relativePath, ".py") {
+		return
+	}
+
+	fullPath := filepath.Join(installRoot, filepath.FromSlash(relativePath))
+	stem := strings.TrimSuffix(filepath.Base(fullPath), ".py")
+
+	pycFiles, err := filepath.Glob(filepath.Join(filepath.Dir(fullPath), "__pycache__", stem+".*.pyc"))
+	if err != nil {
+		return
+	}
+
+	for _, pycFile := range pycFiles {
+		if relativePyc, err := filepath.Rel(installRoot, pycFile); err == nil {
+			_ = common.RemoveFileAndEmptyParents(installRoot, relativePyc)
+		}
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/maja42/ember"
+	"io"
+	"lukasolson.net/common"
+	"os"
+)
+
+// upgradeInstallation brings an existing installation up to date with the embedded files.
+// Only files that were added or changed since the recorded install are extracted, and files
+// that are no longer shipped are removed. It reports whether the requirements file changed.
+func upgradeInstallation(attachments *ember.Attachments, manifest *common.InstallManifest, incoming common.InstallDigests, componentDirs map[string]string, requirementsHash string) (bool, error) {
+
+	for component, dir := range componentDirs {
+		changes := common.DiffFileHashes(manifest.Components[component], incoming[component])
+
+		if len(changes.Changed) == 0 && len(changes.Removed) == 0 {
+			continue
+		}
+
+		fmt.Printf("Updating %s: %d changed, %d removed\n", component, len(changes.Changed), len(changes.Removed))
+
+		if len(changes.Changed) > 0 {
+			reader := attachments.Reader(component)
+			if reader == nil {
+				return false, fmt.Errorf("error reading %s. Ensure it is embedded in the binary", component)
+			}
+
+			changed := make(map[string]bool, len(changes.Changed))
+			for _, relativePath := range changes.Changed {
+				changed[relativePath] = true
+			}
+
+			err := common.StreamToDirFiltered(reader, dir, func(relativePath string) bool {
+				return changed[relativePath]
+			})
+			if err != nil {
+				return false, fmt.Errorf("error extracting updated %s files: %w", component, err)
+			}
+		}
+
+		for _, relativePath := range changes.Removed {
+			fmt.Println("Removing:", relativePath)
+			if err := common.RemoveFileAndEmptyParents(dir, relativePath); err != nil {
+				return false, fmt.Errorf("error removing %s: %w", relativePath, err)
+			}
+		}
+	}
+
+	return manifest.RequirementsHash != requirementsHash, nil
+}
+
+// checkInstalledVersion compares the installer with the application recorded in an existing install manifest,
+// applying the version policy. It reports whether the installer is another version of the installed application,
+// and fails if the directory holds a different application or the policy forbids the downgrade.
+// Installations whose manifest predates the version metadata are not checked.
+func checkInstalledVersion(manifestPath string, settings common.PythonSetupSettings) (bool, error) {
+	manifest, err := common.LoadInstallManifest(manifestPath)
+	if os.IsNotExist(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("error reading install manifest: %w", err)
+	}
+
+	if manifest.AppID == "" || manifest.Version == "" {
+		return false, nil
+	}
+
+	if manifest.AppID != settings.AppIdentifier() {
+		return false, fmt.Errorf("the install directory contains a different application (%s); choose another directory with --install-dir", manifest.AppID)
+	}
+
+	version := *settings.Version
+
+	if common.CompareVersions(version, manifest.Version) == 0 {
+		return false, nil
+	}
+
+	// side-by-side versions have folders of their own, so another version here is never replaced
+	if *settings.VersionPolicy == common.VersionPolicySideBySide {
+		return false, fmt.Errorf("version %s of %s is installed in this directory; with the sideBySide policy each version needs its own directory", manifest.Version, manifest.AppID)
+	}
+
+	switch common.CompareVersions(version, manifest.Version) {
+	case 1:
+		fmt.Printf("Upgrading %s from version %s to %s.\n", manifest.AppID, manifest.Version, version)
+		return true, nil
+	}
+
+	if *settings.VersionPolicy == common.VersionPolicyUpgrade {
+		if !common.AskYesNo(fmt.Sprintf("Version %s of %s is installed. Downgrade to version %s?", manifest.Version, manifest.AppID, version), false) {
+			return false, errors.New("downgrade cancelled")
+		}
+		return true, nil
+	}
+
+	return false, fmt.Errorf("version %s of %s is installed; refusing to downgrade to version %s", manifest.Version, manifest.AppID, version)
+}
+
+// loadInstallManifest reads the manifest of a previous installation.
+// Installations that predate the manifest are treated as having no recorded files, so every file is refreshed.
+func loadInstallManifest(manifestPath string) (*common.InstallManifest, error) {
+	manifest, err := common.LoadInstallManifest(manifestPath)
+	if os.IsNotExist(err) {
+		return common.NewInstallManifest(nil, "", "", common.InstallDigests{}), nil
+	}
+	return manifest, err
+}
+
+func GetInstallDigests(attachments *ember.Attachments) (common.InstallDigests, error) {
+	digestsReader := attachments.Reader(common.InstallDigestsFilename)
+	if digestsReader == nil {
+		return nil, fmt.Errorf("error reading install digests. Ensure they are embedded in the binary")
+	}
+
+	data, err := io.ReadAll(digestsReader)
+	if err != nil {
+		return nil, err
+	}
+
+	var digests common.InstallDigests
+	if err := json.Unmarshal(data, &digests); err != nil {
+		return nil, fmt.Errorf("error unmarshalling install digests: %w", err)
+	}
+
+	return digests, nil
+}
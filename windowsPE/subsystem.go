@@ -0,0 +1,47 @@
+package windowsPE
+
+import (
+	"encoding/binary"
+	"errors"
+)
+
+// Subsystems of a PE image that decide whether Windows gives the process a console.
+const (
+	SubsystemWindowsGUI uint16 = 2
+	SubsystemWindowsCUI uint16 = 3
+)
+
+// SetSubsystem sets the subsystem field of the optional header in a PE file.
+// A GUI executable starts without a console window; a CUI executable gets one.
+func SetSubsystem(peBytes []byte, subsystem uint16) ([]byte, error) {
+	if len(peBytes) < 64 {
+		return nil, errors.New("file is too small to be a PE file")
+	}
+
+	peOffset := int(binary.LittleEndian.Uint32(peBytes[0x3C : 0x3C+4]))
+	if len(peBytes) < peOffset+4 {
+		return nil, errors.New("file is too small to be a PE file")
+	}
+
+	if string(peBytes[peOffset:peOffset+4]) != "PE\x00\x00" {
+		return nil, errors.New("invalid PE signature")
+	}
+
+	optionalHeaderOffset := peOffset + 4 + 20
+
+	// The subsystem is at the same offset in PE32 and PE32+ optional headers.
+	subsystemOffset := optionalHeaderOffset + 68
+	if len(peBytes) < subsystemOffset+2 {
+		return nil, errors.New("optional header too small")
+	}
+
+	switch magic := binary.LittleEndian.Uint16(peBytes[optionalHeaderOffset : optionalHeaderOffset+2]); magic {
+	case 0x10b, 0x20b:
+	default:
+		return nil, errors.New("unknown optional header magic")
+	}
+
+	binary.LittleEndian.PutUint16(peBytes[subsystemOffset:subsystemOffset+2], subsystem)
+
+	return peBytes, nil
+}